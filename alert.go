@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// thresholdFlags 支持重复传入的 -alert-threshold column=value 参数
+type thresholdFlags map[string]float64
+
+func (t thresholdFlags) String() string {
+	var pairs []string
+	for _, k := range sortedThresholdKeys(t) {
+		pairs = append(pairs, fmt.Sprintf("%s=%g", k, t[k]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (t thresholdFlags) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("alert threshold must be in column=value form, got %q", value)
+	}
+	v, err := strconv.ParseFloat(kv[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid alert threshold %q: %v", value, err)
+	}
+	t[kv[0]] = v
+	return nil
+}
+
+func sortedThresholdKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateAlertThresholds 检查告警阈值引用的列都会输出
+func validateAlertThresholds(thresholds map[string]float64) error {
+	columns := map[string]bool{}
+	for _, c := range outputColumns() {
+		columns[c.Name] = true
+	}
+	for _, name := range sortedThresholdKeys(thresholds) {
+		if !columns[name] {
+			return fmt.Errorf("alert threshold column %q is not in the report", name)
+		}
+	}
+	return nil
+}
+
+// alert 一个工作负载的某一列超过了告警阈值
+type alert struct {
+	Result    workloadResult
+	Column    string
+	Value     float64
+	Threshold float64
+}
+
+// checkAlerts 返回所有超过告警阈值的单元格，没有数据的单元格不会触发告警
+func checkAlerts(results []workloadResult, thresholds map[string]float64) []alert {
+	var alerts []alert
+	for _, r := range results {
+		for _, name := range sortedThresholdKeys(thresholds) {
+			if v, ok := r.Values[name]; ok && v > thresholds[name] {
+				alerts = append(alerts, alert{Result: r, Column: name, Value: v, Threshold: thresholds[name]})
+			}
+		}
+	}
+	return alerts
+}
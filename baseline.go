@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/coderwangke/tke-workload-metrics/collector"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// baseline 基线报告中每个工作负载各列的值，key 为 resultKey
+var baseline map[string]map[string]float64
+
+// compareWindows 指定了 -baseline-start、-baseline-end，基线来自同一次运行中采集的另一个时间范围
+var compareWindows bool
+
+// baselineColumnName 基线对比列在 workloadResult.Values 中的 key
+func baselineColumnName(m metricColumn) string {
+	return m.Header + " vs Baseline (%)"
+}
+
+// baselineValueColumnName、deltaColumnName 对比两个时间范围时的基线值列和变化百分比列
+func baselineValueColumnName(m metricColumn) string {
+	return m.Header + " Baseline"
+}
+
+func deltaColumnName(m metricColumn) string {
+	return m.Header + " Delta (%)"
+}
+
+// baselineValues 把基线时间范围的采集结果转换为与基线报告相同的格式，key 为列名
+func baselineValues(r workloadResult) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range statColumns() {
+		if v, ok := r.Values[m.Name]; ok {
+			values[m.Header] = v
+		}
+	}
+	return values
+}
+
+// loadBaseline 读取之前生成的 CSV 报告作为基线，无法解析为数字的单元格（如 N/A）会被忽略
+func loadBaseline(path string) (map[string]map[string]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]map[string]float64{}
+	if len(records) == 0 {
+		return result, nil
+	}
+
+	header := records[0]
+	for _, row := range records[1:] {
+		if len(row) != len(header) {
+			continue
+		}
+		var cluster, namespace, kind, workload, container, pod string
+		values := map[string]float64{}
+		for i, h := range header {
+			switch h {
+			case "Cluster":
+				cluster = row[i]
+			case "Namespace":
+				namespace = row[i]
+			case "Kind":
+				kind = row[i]
+			case "Deployment", "Workload":
+				workload = row[i]
+			case "Container":
+				container = row[i]
+			case "Pod":
+				pod = row[i]
+			default:
+				if v, err := parseNumber(row[i]); err == nil {
+					values[h] = v
+				}
+			}
+		}
+		// 旧版本的报告没有 Kind 列，只包含 Deployment
+		if kind == "" {
+			kind = "Deployment"
+		}
+		result[resultKey(workloadResult{Cluster: cluster, Namespace: namespace, Kind: kind, Name: workload, Container: container, Pod: pod})] = values
+	}
+	return result, nil
+}
+
+// applyBaseline 计算当前统计值占基线值的百分比，没有基线或基线为 0 时不输出
+func applyBaseline(r *workloadResult) {
+	base, ok := baseline[resultKey(*r)]
+	if compareWindows && r.Err == nil {
+		switch {
+		case len(r.Points) > 0 && !ok:
+			r.Comparison = "added"
+		case len(r.Points) == 0 && ok:
+			r.Comparison = "removed"
+		}
+	}
+	if !ok {
+		return
+	}
+	for _, m := range statColumns() {
+		b, hasBaseline := base[m.Header]
+		if compareWindows && hasBaseline {
+			r.Values[baselineValueColumnName(m)] = b
+		}
+		v, ok := r.Values[m.Name]
+		if !ok || !hasBaseline || b == 0 {
+			continue
+		}
+		if compareWindows {
+			r.Values[deltaColumnName(m)] = (v - b) / b * 100
+		} else {
+			r.Values[baselineColumnName(m)] = v / b * 100
+		}
+	}
+}
+
+// deletedWorkloads 从云监控中找出基线时间范围内在 namespaces 下有数据、但已经不在 listed 中的工作负载，
+// 同样按 -name-filter、-exclude 过滤。查询失败时只记录日志，这些工作负载不会被标记为 removed
+func deletedWorkloads(ctx context.Context, col *collector.Collector, namespaces []string, listed []workload, startTime, endTime time.Time, include, exclude *regexp.Regexp) []workload {
+	exists := map[string]bool{}
+	for _, w := range listed {
+		exists[w.Namespace+"/"+w.Kind+"/"+w.Name] = true
+	}
+	var deleted []workload
+	for _, ns := range namespaces {
+		found, err := col.Workloads(ctx, ns, config.WorkloadKinds, startTime, endTime)
+		if err != nil {
+			klog.Warningf("Error finding deleted workloads in %s, they are not reported as removed: %v", ns, err)
+			continue
+		}
+		for _, w := range found {
+			if exists[w.Namespace+"/"+w.Kind+"/"+w.Name] || (include != nil && !include.MatchString(w.Name)) || (exclude != nil && exclude.MatchString(w.Name)) {
+				continue
+			}
+			deleted = append(deleted, workload{Kind: w.Kind, ObjectMeta: metav1.ObjectMeta{Namespace: w.Namespace, Name: w.Name}})
+		}
+	}
+	if len(deleted) > 0 {
+		klog.Infof("found %d workloads in the baseline window that no longer exist", len(deleted))
+	}
+	return deleted
+}
+
+// removedResults 为基线时间范围内有数据、当前时间范围没有对应结果的工作负载生成 Comparison 为 removed 的行，
+// 只包含基线值列。容器行和 Pod 行不输出
+func removedResults(baselineResults, current []workloadResult) []workloadResult {
+	exists := map[string]bool{}
+	for _, r := range current {
+		exists[resultKey(r)] = true
+	}
+	var removed []workloadResult
+	for _, b := range baselineResults {
+		if !b.isWorkloadRow() || exists[resultKey(b)] {
+			continue
+		}
+		r := workloadResult{Cluster: b.Cluster, Namespace: b.Namespace, Kind: b.Kind, Name: b.Name, Values: map[string]float64{}, Comparison: "removed"}
+		for _, m := range statColumns() {
+			if v, ok := b.Values[m.Name]; ok {
+				r.Values[baselineValueColumnName(m)] = v
+			}
+		}
+		removed = append(removed, r)
+	}
+	return removed
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemovedResults(t *testing.T) {
+	saved := config.Metrics
+	defer func() { config.Metrics = saved }()
+	config.Metrics = []metricColumn{{Name: cpuRequestRatioMetric, Header: "CPU Usage Max (percent)"}}
+
+	baselineResults := []workloadResult{
+		{Cluster: "prod", Namespace: "default", Kind: "Deployment", Name: "api", Values: map[string]float64{cpuRequestRatioMetric: 40}},
+		// old-api 在基线时间范围之后被删除，当前时间范围没有对应的结果
+		{Cluster: "prod", Namespace: "default", Kind: "Deployment", Name: "old-api", Values: map[string]float64{cpuRequestRatioMetric: 70}},
+		{Cluster: "prod", Namespace: "default", Kind: "Deployment", Name: "old-api", Container: "sidecar", Values: map[string]float64{cpuRequestRatioMetric: 5}},
+	}
+	current := []workloadResult{
+		{Cluster: "prod", Namespace: "default", Kind: "Deployment", Name: "api", Values: map[string]float64{cpuRequestRatioMetric: 50}},
+	}
+
+	want := []workloadResult{{
+		Cluster:    "prod",
+		Namespace:  "default",
+		Kind:       "Deployment",
+		Name:       "old-api",
+		Values:     map[string]float64{"CPU Usage Max (percent) Baseline": 70},
+		Comparison: "removed",
+	}}
+	if got := removedResults(baselineResults, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("removedResults = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coderwangke/tke-workload-metrics/collector"
+	"k8s.io/klog/v2"
+)
+
+// fileCache 把工作负载列表和采集到的原始数据点缓存到 -cache-dir，-cache-ttl 内重复运行时不再调用 API。
+// 每个 key 对应一个 JSON 文件，按文件的修改时间判断是否过期
+type fileCache struct {
+	dir string
+	ttl time.Duration
+	// refresh 忽略已有的缓存，但仍然写入新的结果
+	refresh bool
+}
+
+// cache 未指定 -cache-dir 时为 nil，不使用缓存
+var cache *fileCache
+
+func (c *fileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get 读取未过期的缓存到 v，缓存不存在、已过期或无法解析时返回 false
+func (c *fileCache) get(key string, v interface{}) bool {
+	if c == nil || c.refresh {
+		return false
+	}
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > c.ttl {
+		return false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		klog.Warningf("ignoring invalid cache file %s: %v", path, err)
+		return false
+	}
+	return true
+}
+
+// put 写入缓存，失败时只记录日志
+func (c *fileCache) put(key string, v interface{}) {
+	if c == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		klog.Warningf("Error encoding cache entry: %v", err)
+		return
+	}
+	path := c.path(key)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		klog.Warningf("Error writing cache file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		klog.Warningf("Error writing cache file: %v", err)
+	}
+}
+
+// collectCached 优先从缓存读取工作负载的数据点，采集失败的结果不会写入缓存
+func collectCached(ctx context.Context, col *collector.Collector, clusterID string, target collector.Workload, startTime, endTime time.Time) (collector.Result, error) {
+	key := strings.Join([]string{"metrics", clusterID, config.MonitorNamespace, target.String(),
+		startTime.UTC().Format(time.RFC3339), endTime.UTC().Format(time.RFC3339), strconv.FormatUint(period, 10),
+		strings.Join(requestedMetrics(), ","), fmt.Sprint(containerMetrics())}, "\x00")
+	result := collector.Result{Workload: target}
+	if cache.get(key, &result.Points) {
+		klog.V(3).Infof("using cached metrics of %s", target)
+		// 不完整响应的指标单独缓存，旧的缓存文件中没有时视为完整
+		cache.get(key+"\x00partial", &result.Partial)
+		return result, nil
+	}
+	result, err := col.CollectWorkload(ctx, target, startTime, endTime)
+	if err == nil {
+		cache.put(key, result.Points)
+		cache.put(key+"\x00partial", result.Partial)
+	}
+	return result, err
+}
+
+// listWorkloadsCached 优先从缓存读取命名空间下的工作负载及其 request、limit，-discover-pods 时通过 Pod 发现工作负载
+func listWorkloadsCached(ctx context.Context, cl ClusterConfig, clients clusterClients, namespace string) ([]workload, error) {
+	key := strings.Join([]string{"workloads", cl.ClusterID, cl.Context, namespace, selector, strings.Join(config.WorkloadKinds, ","), strconv.FormatBool(discoverPods), strconv.FormatBool(dedupOwned)}, "\x00")
+	var workloads []workload
+	if cache.get(key, &workloads) {
+		klog.V(3).Infof("using cached workloads of %s", namespace)
+		return workloads, nil
+	}
+	var err error
+	if discoverPods {
+		workloads, err = discoverWorkloads(ctx, clients, namespace, selector)
+	} else {
+		workloads, err = listWorkloads(ctx, clients, namespace, selector, config.WorkloadKinds)
+	}
+	if err == nil {
+		cache.put(key, workloads)
+	}
+	return workloads, err
+}
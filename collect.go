@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coderwangke/tke-workload-metrics/collector"
+	"k8s.io/klog/v2"
+)
+
+// collectWorkloads 使用 concurrency 个 goroutine 并发采集所有工作负载的监控数据。
+// 工作负载按 collectionOrder 的顺序进入队列，结果按 workloads 的顺序返回，
+// 单个工作负载采集失败只记录日志，不影响其他工作负载。ctx 被取消时停止采集，
+// 只返回已经完成的工作负载。-containers 时每个工作负载行后紧跟有数据的容器行，-granularity pod 时紧跟有数据的 Pod 行，
+// 并在工作负载行计算 Pod 之间的倾斜度。
+// 每个结果完成时在当前 goroutine 中调用 finish，用于计算派生列并写入 -flush-every 的中间文件
+func collectWorkloads(ctx context.Context, col *collector.Collector, clusterID string, workloads []workload, startTime, endTime time.Time, concurrency int, finish func(*workloadResult)) []workloadResult {
+	type collected struct {
+		index      int
+		points     map[string][]float64
+		partial    []string
+		err        error
+		containers []workloadResult
+		pods       []workloadResult
+	}
+
+	jobs := make(chan int)
+	out := make(chan collected)
+
+	var wg sync.WaitGroup
+	for n := 0; n < concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result, err := collectCached(ctx, col, clusterID, workloads[i].target(), startTime, endTime)
+				if err != nil {
+					klog.Warningf("collect %s/%s/%s metrics failed: %v", workloads[i].Namespace, workloads[i].Kind, workloads[i].Name, err)
+				}
+				c := collected{index: i, points: result.Points, partial: result.Partial, err: err}
+				if withContainers && err == nil {
+					c.containers = collectContainers(ctx, col, clusterID, workloads[i], startTime, endTime)
+				}
+				if withPods && err == nil {
+					c.pods = collectPods(ctx, col, clusterID, workloads[i], startTime, endTime)
+				}
+				out <- c
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, i := range collectionOrder(workloads) {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	// 只在当前 goroutine 中写入结果
+	results := make([][]workloadResult, len(workloads))
+	received := 0
+	bar := newProgress(len(workloads))
+	defer bar.finish()
+	for c := range out {
+		received++
+		bar.add()
+		klog.V(2).Infof("collected %d/%d workloads", received, len(workloads))
+		// 被取消而中断的采集既不是成功也不是失败，不输出
+		if c.err != nil && ctx.Err() != nil {
+			continue
+		}
+		w := workloads[c.index]
+		results[c.index] = append([]workloadResult{{
+			Namespace: w.Namespace,
+			Kind:      w.Kind,
+			Name:      w.Name,
+			Resources: w.Resources,
+			Replicas:  w.Replicas,
+			Claims:    w.Claims,
+			Points:    c.points,
+			Partial:   c.partial,
+			Values:    statValues(c.points),
+			Err:       c.err,
+		}}, c.containers...)
+		results[c.index] = append(results[c.index], c.pods...)
+		if withPods {
+			applySkew(&results[c.index][0], c.pods)
+		}
+		for i := range results[c.index] {
+			finish(&results[c.index][i])
+		}
+	}
+
+	var finished []workloadResult
+	for _, r := range results {
+		finished = append(finished, r...)
+	}
+	return finished
+}
+
+// collectContainers 按 container_name 维度采集工作负载的每个容器，只返回有数据的容器。
+// 容器维度的指标没有数据或采集失败时只保留工作负载行
+func collectContainers(ctx context.Context, col *collector.Collector, clusterID string, w workload, startTime, endTime time.Time) []workloadResult {
+	var results []workloadResult
+	for _, c := range w.Containers {
+		target := w.target()
+		target.Container = c.Name
+		result, err := collectCached(ctx, col, clusterID, target, startTime, endTime)
+		if err != nil {
+			klog.Warningf("collect %s metrics failed, keeping only the workload row: %v", target, err)
+			continue
+		}
+		if len(result.Points) == 0 {
+			continue
+		}
+		results = append(results, workloadResult{
+			Namespace: w.Namespace,
+			Kind:      w.Kind,
+			Name:      w.Name,
+			Container: c.Name,
+			Resources: c.Resources,
+			Replicas:  w.Replicas,
+			Points:    result.Points,
+			Partial:   result.Partial,
+			Values:    statValues(result.Points),
+		})
+	}
+	return results
+}
@@ -0,0 +1,499 @@
+// Package collector 从腾讯云监控 DescribeStatisticData 接口采集 TKE 工作负载的监控数据。
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	monitor "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/monitor/v20180724"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+)
+
+// MonitorClient Collector 依赖的云监控接口，*monitor.Client 实现了该接口，测试时可以替换为 fake
+type MonitorClient interface {
+	DescribeStatisticDataWithContext(ctx context.Context, request *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, error)
+}
+
+// Config Collector 的配置，零值字段使用默认值
+type Config struct {
+	// ClusterID TKE 集群 ID
+	ClusterID string
+	// MonitorNamespace 云监控命名空间，默认为 QCE/TKE2
+	MonitorNamespace string
+	// Metrics 需要请求的指标名
+	Metrics []string
+	// KindValues Kubernetes kind 到 workload_kind 维度值的映射，没有映射的 kind 直接使用 kind 本身
+	KindValues map[string]string
+	// Period 统计周期，单位为秒，默认为 3600
+	Period uint64
+	// RetryAttempts 遇到限频和临时错误时的最大尝试次数，默认为 5
+	RetryAttempts int
+	// RetryBaseDelay 第一次重试前的等待时间，之后每次翻倍，默认为 1s
+	RetryBaseDelay time.Duration
+	// Debug 打印原始响应以及每个指标的数据点数量，为 false 时分别由 klog -v=5、-v=4 控制
+	Debug bool
+	// Limiter 限制调用 DescribeStatisticData 的速率，可以在多个 Collector 之间共享，为 nil 时不限速
+	Limiter *rate.Limiter
+	// ContainerMetrics 工作负载指标名到容器维度指标名的映射，采集单个容器时只请求有映射的指标
+	ContainerMetrics map[string]string
+	// PodMetrics 工作负载指标名到 Pod 维度指标名的映射，采集单个 Pod 时只请求有映射的指标
+	PodMetrics map[string]string
+}
+
+// Workload 需要采集的工作负载
+type Workload struct {
+	Namespace string
+	Kind      string
+	Name      string
+	// Container 不为空时按 container_name 维度采集工作负载中的单个容器
+	Container string
+	// Pod 不为空时按 pod_name 维度采集工作负载中的单个 Pod
+	Pod string
+}
+
+// String 返回 namespace/kind/name，采集单个容器或 Pod 时以容器名或 Pod 名结尾
+func (w Workload) String() string {
+	s := w.Namespace + "/" + w.Kind + "/" + w.Name
+	if w.Container != "" {
+		s += "/" + w.Container
+	}
+	if w.Pod != "" {
+		s += "/" + w.Pod
+	}
+	return s
+}
+
+// Result 单个工作负载的采集结果
+type Result struct {
+	Workload Workload
+	// Points 每个指标在时间范围内按时间排序的数据点，没有数据点的指标不会出现在其中。
+	// 采集单个容器时 key 仍为工作负载指标名
+	Points map[string][]float64
+	// Partial 请求成功但响应中没有有效数据点的指标，只在同一响应中的其他指标有数据时记录，按出现顺序去重
+	Partial []string
+}
+
+// Collector 采集工作负载的监控数据，可以被多个 goroutine 同时使用
+type Collector struct {
+	client MonitorClient
+	config Config
+	tracer trace.Tracer
+}
+
+// New 创建一个 Collector，span 通过创建时的全局 TracerProvider 导出
+func New(client MonitorClient, config Config) *Collector {
+	if config.MonitorNamespace == "" {
+		config.MonitorNamespace = "QCE/TKE2"
+	}
+	if config.Period == 0 {
+		config.Period = 3600
+	}
+	if config.RetryAttempts < 1 {
+		config.RetryAttempts = 5
+	}
+	if config.RetryBaseDelay == 0 {
+		config.RetryBaseDelay = time.Second
+	}
+	return &Collector{
+		client: client,
+		config: config,
+		tracer: otel.Tracer("github.com/coderwangke/tke-workload-metrics/collector"),
+	}
+}
+
+// ValidPeriod 判断 period 是否为 DescribeStatisticData 支持的统计周期
+func ValidPeriod(period uint64) bool {
+	_, ok := periodRanges[period]
+	return ok
+}
+
+// CollectWorkload 返回工作负载每个指标在 [startTime, endTime] 内的数据点。
+// 时间范围超过单次请求允许的范围时拆分为多个时间窗口分别请求，重试后仍然失败时返回错误
+func (c *Collector) CollectWorkload(ctx context.Context, w Workload, startTime, endTime time.Time) (Result, error) {
+	klog.V(3).Infof("start collect %s metrics.", w)
+
+	var metricRawData []*monitor.MetricData
+	var partial []string
+	for _, request := range c.Plan(w, startTime, endTime) {
+		batch := common.StringValues(request.MetricNames)
+		// 返回的resp是一个DescribeStatisticDataResponse的实例，与请求对象对应
+		callCtx, span := c.tracer.Start(ctx, "DescribeStatisticData", trace.WithAttributes(
+			attribute.String("namespace", w.Namespace),
+			attribute.String("kind", w.Kind),
+			attribute.String("workload", w.Name),
+			attribute.String("container", w.Container),
+			attribute.String("pod", w.Pod),
+			attribute.StringSlice("metrics", batch),
+			attribute.String("start", *request.StartTime),
+			attribute.String("end", *request.EndTime),
+		))
+		response, retries, err := c.describeStatisticData(callCtx, request)
+		span.SetAttributes(attribute.Int("retries", retries))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		if err != nil {
+			// 重试后仍然失败的结果不能当作没有数据
+			return Result{Workload: w}, fmt.Errorf("DescribeStatisticData failed after %d retries: %v", retries, err)
+		}
+
+		if c.config.Debug || klog.V(5).Enabled() {
+			klog.Infof("collect %s raw metrics %s.", w, response.ToJsonString())
+		}
+
+		// 接口返回成功时也可能只包含部分指标的数据
+		if missing := incompleteMetrics(batch, response.Response.Data); len(missing) > 0 && len(missing) < len(batch) {
+			klog.Warningf("partial response for %s, no valid data points for metrics %v (RequestId: %s)", w, missing, common.StringValues([]*string{response.Response.RequestId})[0])
+			partial = appendUnique(partial, missing...)
+		}
+
+		metricRawData = append(metricRawData, response.Response.Data...)
+	}
+
+	result := Result{Workload: w, Points: mergePoints(metricRawData), Partial: partial}
+	if mapping := c.metricMapping(w); mapping != nil {
+		result.Points = c.workloadMetricPoints(result.Points, mapping)
+		result.Partial = c.workloadMetricNames(result.Partial, mapping)
+	}
+	if c.config.Debug || klog.V(4).Enabled() {
+		for _, name := range c.config.Metrics {
+			if values, ok := result.Points[name]; ok {
+				klog.Infof("collect %s metric %s: %d data points.", w, name, len(values))
+			} else {
+				klog.Infof("collect %s metric %s: no data points.", w, name)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Plan 返回采集工作负载时会发送的 DescribeStatisticData 请求，不调用接口。
+// 时间范围超过单次请求允许的范围、指标数量超过单次请求上限时都拆分为多次请求
+func (c *Collector) Plan(w Workload, startTime, endTime time.Time) []*monitor.DescribeStatisticDataRequest {
+	var requests []*monitor.DescribeStatisticDataRequest
+	for _, window := range timeWindows(startTime, endTime, periodRanges[c.config.Period]) {
+		for _, batch := range batchMetrics(c.metrics(w), maxMetricsPerRequest) {
+			// 实例化一个请求对象,每个接口都会对应一个request对象
+			request := monitor.NewDescribeStatisticDataRequest()
+
+			request.Module = common.StringPtr("monitor")
+			request.Namespace = common.StringPtr(c.config.MonitorNamespace)
+			request.MetricNames = common.StringPtrs(batch)
+			request.Conditions = []*monitor.MidQueryCondition{
+				{
+					Key:      common.StringPtr("tke_cluster_instance_id"),
+					Operator: common.StringPtr("="),
+					Value:    common.StringPtrs([]string{c.config.ClusterID}),
+				},
+				{
+					Key:      common.StringPtr("namespace"),
+					Operator: common.StringPtr("="),
+					Value:    common.StringPtrs([]string{w.Namespace}),
+				},
+				{
+					Key:      common.StringPtr("workload_kind"),
+					Operator: common.StringPtr("="),
+					Value:    common.StringPtrs([]string{c.kindValue(w.Kind)}),
+				},
+				{
+					Key:      common.StringPtr("workload_name"),
+					Operator: common.StringPtr("="),
+					Value:    common.StringPtrs([]string{w.Name}),
+				},
+			}
+			if w.Container != "" {
+				request.Conditions = append(request.Conditions, &monitor.MidQueryCondition{
+					Key:      common.StringPtr("container_name"),
+					Operator: common.StringPtr("="),
+					Value:    common.StringPtrs([]string{w.Container}),
+				})
+			}
+			if w.Pod != "" {
+				request.Conditions = append(request.Conditions, &monitor.MidQueryCondition{
+					Key:      common.StringPtr("pod_name"),
+					Operator: common.StringPtr("="),
+					Value:    common.StringPtrs([]string{w.Pod}),
+				})
+			}
+
+			request.Period = common.Uint64Ptr(c.config.Period)
+			request.StartTime = common.StringPtr(window[0].Format(time.RFC3339))
+			request.EndTime = common.StringPtr(window[1].Format(time.RFC3339))
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// Workloads 按 workload_kind、workload_name 维度分组查询命名空间下的第一个指标，返回 [startTime, endTime] 内有数据、
+// kind 属于 kinds 的工作负载，按 kind、名称排序。用于找出时间范围内存在、但已经从集群中删除的工作负载
+func (c *Collector) Workloads(ctx context.Context, namespace string, kinds []string, startTime, endTime time.Time) ([]Workload, error) {
+	if len(c.config.Metrics) == 0 {
+		return nil, nil
+	}
+	// workload_kind 维度值到 Kubernetes kind 的映射
+	kindOf := map[string]string{}
+	for _, kind := range kinds {
+		kindOf[c.kindValue(kind)] = kind
+	}
+
+	seen := map[Workload]bool{}
+	for _, window := range timeWindows(startTime, endTime, periodRanges[c.config.Period]) {
+		request := monitor.NewDescribeStatisticDataRequest()
+		request.Module = common.StringPtr("monitor")
+		request.Namespace = common.StringPtr(c.config.MonitorNamespace)
+		request.MetricNames = common.StringPtrs(c.config.Metrics[:1])
+		request.Conditions = []*monitor.MidQueryCondition{
+			{
+				Key:      common.StringPtr("tke_cluster_instance_id"),
+				Operator: common.StringPtr("="),
+				Value:    common.StringPtrs([]string{c.config.ClusterID}),
+			},
+			{
+				Key:      common.StringPtr("namespace"),
+				Operator: common.StringPtr("="),
+				Value:    common.StringPtrs([]string{namespace}),
+			},
+		}
+		request.GroupBys = common.StringPtrs([]string{"workload_kind", "workload_name"})
+		request.Period = common.Uint64Ptr(c.config.Period)
+		request.StartTime = common.StringPtr(window[0].Format(time.RFC3339))
+		request.EndTime = common.StringPtr(window[1].Format(time.RFC3339))
+
+		response, retries, err := c.describeStatisticData(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("DescribeStatisticData failed after %d retries: %v", retries, err)
+		}
+		for _, data := range response.Response.Data {
+			for _, p := range data.Points {
+				if !hasValue(p.Values) {
+					continue
+				}
+				dimensions := map[string]string{}
+				for _, d := range p.Dimensions {
+					if d.Name != nil && d.Value != nil {
+						dimensions[*d.Name] = *d.Value
+					}
+				}
+				kind, ok := kindOf[dimensions["workload_kind"]]
+				if !ok || dimensions["workload_name"] == "" {
+					continue
+				}
+				seen[Workload{Namespace: namespace, Kind: kind, Name: dimensions["workload_name"]}] = true
+			}
+		}
+	}
+
+	var workloads []Workload
+	for w := range seen {
+		workloads = append(workloads, w)
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Kind != workloads[j].Kind {
+			return workloads[i].Kind < workloads[j].Kind
+		}
+		return workloads[i].Name < workloads[j].Name
+	})
+	return workloads, nil
+}
+
+// hasValue 判断数据点中是否至少有一个有效值
+func hasValue(values []*monitor.Point) bool {
+	for _, v := range values {
+		if v.Value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// metricMapping 返回采集单个容器或 Pod 时使用的指标名映射，采集整个工作负载时返回 nil
+func (c *Collector) metricMapping(w Workload) map[string]string {
+	if w.Container != "" {
+		return c.config.ContainerMetrics
+	}
+	if w.Pod != "" {
+		return c.config.PodMetrics
+	}
+	return nil
+}
+
+// metrics 返回采集工作负载时请求的指标名，采集单个容器或 Pod 时为有映射的容器或 Pod 维度指标
+func (c *Collector) metrics(w Workload) []string {
+	mapping := c.metricMapping(w)
+	if mapping == nil {
+		return c.config.Metrics
+	}
+	var names []string
+	for _, name := range c.config.Metrics {
+		if m, ok := mapping[name]; ok {
+			names = append(names, m)
+		}
+	}
+	return names
+}
+
+// workloadMetricPoints 把容器或 Pod 维度指标的数据点换回对应的工作负载指标名，使它们和工作负载的结果使用相同的列
+func (c *Collector) workloadMetricPoints(points map[string][]float64, mapping map[string]string) map[string][]float64 {
+	result := map[string][]float64{}
+	for _, name := range c.config.Metrics {
+		if values, ok := points[mapping[name]]; ok {
+			result[name] = values
+		}
+	}
+	return result
+}
+
+// workloadMetricNames 把容器或 Pod 维度的指标名换回对应的工作负载指标名
+func (c *Collector) workloadMetricNames(names []string, mapping map[string]string) []string {
+	workloadNames := map[string]string{}
+	for _, name := range c.config.Metrics {
+		if m, ok := mapping[name]; ok {
+			workloadNames[m] = name
+		}
+	}
+	var result []string
+	for _, name := range names {
+		if w, ok := workloadNames[name]; ok {
+			name = w
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// appendUnique 追加 names 中 list 里还没有的名称
+func appendUnique(list []string, names ...string) []string {
+	for _, name := range names {
+		found := false
+		for _, existing := range list {
+			if existing == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// kindValue 返回 kind 对应的 workload_kind 维度值
+func (c *Collector) kindValue(kind string) string {
+	if v, ok := c.config.KindValues[kind]; ok {
+		return v
+	}
+	return kind
+}
+
+// timeWindows 把 [start, end] 按 size 拆分为多个相邻的时间窗口
+func timeWindows(start, end time.Time, size time.Duration) [][2]time.Time {
+	var windows [][2]time.Time
+	for end.Sub(start) > size {
+		windows = append(windows, [2]time.Time{start, start.Add(size)})
+		start = start.Add(size)
+	}
+	return append(windows, [2]time.Time{start, end})
+}
+
+// mergePoints 合并各个时间窗口返回的数据点，相邻窗口边界上重复的时间点只保留一个
+func mergePoints(data []*monitor.MetricData) map[string][]float64 {
+	type timedValue struct {
+		ts    uint64
+		value float64
+	}
+	points := map[string][]timedValue{}
+	seen := map[string]map[uint64]bool{}
+	for _, metric := range data {
+		if metric.MetricName == nil || len(metric.Points) == 0 {
+			continue
+		}
+		name := *metric.MetricName
+		if seen[name] == nil {
+			seen[name] = map[uint64]bool{}
+		}
+		for _, point := range metric.Points[0].Values {
+			if point.Value == nil {
+				continue
+			}
+			var ts uint64
+			if point.Timestamp != nil {
+				ts = *point.Timestamp
+				if seen[name][ts] {
+					continue
+				}
+				seen[name][ts] = true
+			}
+			points[name] = append(points[name], timedValue{ts: ts, value: *point.Value})
+		}
+	}
+
+	result := map[string][]float64{}
+	for name, values := range points {
+		sort.SliceStable(values, func(i, j int) bool { return values[i].ts < values[j].ts })
+		for _, v := range values {
+			result[name] = append(result[name], v.value)
+		}
+	}
+	return result
+}
+
+// periodRanges DescribeStatisticData 各统计周期单次请求允许查询的最大时间范围，超出时接口返回空数据而不是报错
+var periodRanges = map[uint64]time.Duration{
+	60:    12 * time.Hour,
+	300:   3 * 24 * time.Hour,
+	3600:  30 * 24 * time.Hour,
+	86400: 186 * 24 * time.Hour,
+}
+
+// maxMetricsPerRequest DescribeStatisticData 单次请求允许的最大 MetricNames 数量
+const maxMetricsPerRequest = 10
+
+// batchMetrics 把指标列表按 size 拆分
+func batchMetrics(names []string, size int) [][]string {
+	var batches [][]string
+	for len(names) > size {
+		batches = append(batches, names[:size])
+		names = names[size:]
+	}
+	if len(names) > 0 {
+		batches = append(batches, names)
+	}
+	return batches
+}
+
+// incompleteMetrics 返回请求了但响应中缺失、或者所有数据点都为 null 的指标
+func incompleteMetrics(requested []string, data []*monitor.MetricData) []string {
+	valid := map[string]bool{}
+	for _, metric := range data {
+		if metric.MetricName == nil {
+			continue
+		}
+		for _, p := range metric.Points {
+			for _, v := range p.Values {
+				if v.Value != nil {
+					valid[*metric.MetricName] = true
+				}
+			}
+		}
+	}
+
+	var missing []string
+	for _, name := range requested {
+		if !valid[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,352 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+	monitor "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/monitor/v20180724"
+)
+
+// fakeClient 记录收到的请求，按 respond 返回响应
+type fakeClient struct {
+	mu       sync.Mutex
+	requests []*monitor.DescribeStatisticDataRequest
+	respond  func(n int, request *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, error)
+}
+
+func (f *fakeClient) DescribeStatisticDataWithContext(ctx context.Context, request *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, error) {
+	f.mu.Lock()
+	n := len(f.requests)
+	f.requests = append(f.requests, request)
+	f.mu.Unlock()
+	return f.respond(n, request)
+}
+
+// parseResponse 解析 DescribeStatisticData 的原始响应
+func parseResponse(t *testing.T, raw string) *monitor.DescribeStatisticDataResponse {
+	t.Helper()
+	response := monitor.NewDescribeStatisticDataResponse()
+	if err := json.Unmarshal([]byte(raw), response); err != nil {
+		t.Fatalf("parse canned response: %v", err)
+	}
+	return response
+}
+
+// partialResponse 一个接口返回成功但 K8sWorkloadRateMemWorkingSetBytesRequestMax 所有数据点都为 null 的响应
+const partialResponse = `{
+  "Response": {
+    "Period": 3600,
+    "StartTime": "2024-07-18T00:00:00Z",
+    "EndTime": "2024-07-18T03:00:00Z",
+    "Data": [
+      {
+        "MetricName": "K8sWorkloadRateCpuCoreUsedRequestMax",
+        "Points": [{"Dimensions": [], "Values": [{"Timestamp": 1721260800, "Value": 10}, {"Timestamp": 1721264400, "Value": 30}]}]
+      },
+      {
+        "MetricName": "K8sWorkloadRateMemWorkingSetBytesRequestMax",
+        "Points": [{"Dimensions": [], "Values": [{"Timestamp": 1721260800, "Value": null}, {"Timestamp": 1721264400, "Value": null}]}]
+      }
+    ],
+    "RequestId": "partial-request-id"
+  }
+}`
+
+func TestCollectWorkloadPartialResponse(t *testing.T) {
+	client := &fakeClient{respond: func(int, *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, error) {
+		return parseResponse(t, partialResponse), nil
+	}}
+	c := New(client, Config{
+		ClusterID: "cls-test",
+		Metrics:   []string{"K8sWorkloadRateCpuCoreUsedRequestMax", "K8sWorkloadRateMemWorkingSetBytesRequestMax"},
+	})
+
+	start := time.Date(2024, 7, 18, 0, 0, 0, 0, time.UTC)
+	result, err := c.CollectWorkload(context.Background(), Workload{Namespace: "default", Kind: "Deployment", Name: "api"}, start, start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("CollectWorkload: %v", err)
+	}
+	if want := map[string][]float64{"K8sWorkloadRateCpuCoreUsedRequestMax": {10, 30}}; !reflect.DeepEqual(result.Points, want) {
+		t.Errorf("Points = %v, want %v", result.Points, want)
+	}
+	if want := []string{"K8sWorkloadRateMemWorkingSetBytesRequestMax"}; !reflect.DeepEqual(result.Partial, want) {
+		t.Errorf("Partial = %v, want %v", result.Partial, want)
+	}
+}
+
+// windowResponse 为请求中的每个指标返回时间窗口起止两个时间点的数据，值为距 origin 的小时数，
+// 相邻窗口在边界上返回相同的时间点
+func windowResponse(origin time.Time) func(int, *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, error) {
+	return func(_ int, request *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, error) {
+		response := monitor.NewDescribeStatisticDataResponse()
+		response.Response = &monitor.DescribeStatisticDataResponseParams{}
+		var values []*monitor.Point
+		for _, s := range []*string{request.StartTime, request.EndTime} {
+			ts, _ := time.Parse(time.RFC3339, *s)
+			timestamp := uint64(ts.Unix())
+			value := ts.Sub(origin).Hours()
+			values = append(values, &monitor.Point{Timestamp: &timestamp, Value: &value})
+		}
+		for _, name := range request.MetricNames {
+			response.Response.Data = append(response.Response.Data, &monitor.MetricData{
+				MetricName: name,
+				Points:     []*monitor.MetricDataPoint{{Values: values}},
+			})
+		}
+		return response, nil
+	}
+}
+
+func metricNames(n int) []string {
+	var names []string
+	for i := 0; i < n; i++ {
+		names = append(names, fmt.Sprintf("Metric%02d", i))
+	}
+	return names
+}
+
+func TestPlan(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		period  uint64
+		metrics int
+		end     time.Time
+		// windows 每个时间窗口的起止时间，batches 每个窗口内各批次的指标数量
+		windows [][2]time.Time
+		batches []int
+	}{
+		{
+			name:    "single window",
+			period:  3600,
+			metrics: 4,
+			end:     start.Add(24 * time.Hour),
+			windows: [][2]time.Time{{start, start.Add(24 * time.Hour)}},
+			batches: []int{4},
+		},
+		{
+			name:    "period 60 splits every 12 hours",
+			period:  60,
+			metrics: 1,
+			end:     start.Add(30 * time.Hour),
+			windows: [][2]time.Time{
+				{start, start.Add(12 * time.Hour)},
+				{start.Add(12 * time.Hour), start.Add(24 * time.Hour)},
+				{start.Add(24 * time.Hour), start.Add(30 * time.Hour)},
+			},
+			batches: []int{1},
+		},
+		{
+			name:    "period 300 splits every 3 days",
+			period:  300,
+			metrics: 1,
+			end:     start.Add(3 * 24 * time.Hour),
+			windows: [][2]time.Time{{start, start.Add(3 * 24 * time.Hour)}},
+			batches: []int{1},
+		},
+		{
+			name:    "batches at maxMetricsPerRequest",
+			period:  3600,
+			metrics: 2*maxMetricsPerRequest + 3,
+			end:     start.Add(time.Hour),
+			windows: [][2]time.Time{{start, start.Add(time.Hour)}},
+			batches: []int{maxMetricsPerRequest, maxMetricsPerRequest, 3},
+		},
+		{
+			name:    "exactly maxMetricsPerRequest",
+			period:  3600,
+			metrics: maxMetricsPerRequest,
+			end:     start.Add(time.Hour),
+			windows: [][2]time.Time{{start, start.Add(time.Hour)}},
+			batches: []int{maxMetricsPerRequest},
+		},
+		{
+			name:    "windows and batches",
+			period:  3600,
+			metrics: maxMetricsPerRequest + 1,
+			end:     start.Add(45 * 24 * time.Hour),
+			windows: [][2]time.Time{
+				{start, start.Add(30 * 24 * time.Hour)},
+				{start.Add(30 * 24 * time.Hour), start.Add(45 * 24 * time.Hour)},
+			},
+			batches: []int{maxMetricsPerRequest, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := metricNames(tt.metrics)
+			c := New(&fakeClient{}, Config{ClusterID: "cls-test", Metrics: names, Period: tt.period})
+			requests := c.Plan(Workload{Namespace: "default", Kind: "Deployment", Name: "api"}, start, tt.end)
+			if len(requests) != len(tt.windows)*len(tt.batches) {
+				t.Fatalf("got %d requests, want %d", len(requests), len(tt.windows)*len(tt.batches))
+			}
+			for i, request := range requests {
+				window, batch := tt.windows[i/len(tt.batches)], tt.batches[i%len(tt.batches)]
+				if got, want := *request.StartTime, window[0].Format(time.RFC3339); got != want {
+					t.Errorf("request %d StartTime = %s, want %s", i, got, want)
+				}
+				if got, want := *request.EndTime, window[1].Format(time.RFC3339); got != want {
+					t.Errorf("request %d EndTime = %s, want %s", i, got, want)
+				}
+				if len(request.MetricNames) != batch {
+					t.Errorf("request %d has %d metrics, want %d", i, len(request.MetricNames), batch)
+				}
+				if *request.Period != tt.period {
+					t.Errorf("request %d Period = %d, want %d", i, *request.Period, tt.period)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectWorkloadMergesWindows(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		period uint64
+		end    time.Time
+		want   []float64
+	}{
+		{name: "single window", period: 3600, end: start.Add(10 * time.Hour), want: []float64{0, 10}},
+		{name: "boundary points are kept once", period: 60, end: start.Add(30 * time.Hour), want: []float64{0, 12, 24, 30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{respond: windowResponse(start)}
+			names := metricNames(maxMetricsPerRequest + 1)
+			c := New(client, Config{ClusterID: "cls-test", Metrics: names, Period: tt.period})
+			result, err := c.CollectWorkload(context.Background(), Workload{Namespace: "default", Kind: "Deployment", Name: "api"}, start, tt.end)
+			if err != nil {
+				t.Fatalf("CollectWorkload: %v", err)
+			}
+			for _, name := range names {
+				if !reflect.DeepEqual(result.Points[name], tt.want) {
+					t.Errorf("%s points = %v, want %v", name, result.Points[name], tt.want)
+				}
+			}
+			if len(result.Partial) != 0 {
+				t.Errorf("Partial = %v, want none", result.Partial)
+			}
+		})
+	}
+}
+
+// httpStatusError SDK 收到非 200 响应时返回的错误
+func httpStatusError(status string) error {
+	return errors.NewTencentCloudSDKError("ClientError.HttpStatusCodeError", "Request fail with http status code: "+status+", with body: ", "")
+}
+
+func TestCollectWorkloadRetries(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	limited := errors.NewTencentCloudSDKError("RequestLimitExceeded", "too many requests", "id")
+	tests := []struct {
+		name     string
+		failures []error
+		attempts int
+		// calls 预期的请求次数，wantErr 为 true 时重试后仍然失败
+		calls   int
+		wantErr bool
+	}{
+		{name: "no error", attempts: 3, calls: 1},
+		{name: "retries until success", failures: []error{limited, limited}, attempts: 3, calls: 3},
+		{name: "retries internal errors", failures: []error{errors.NewTencentCloudSDKError("InternalError", "oops", "id")}, attempts: 3, calls: 2},
+		{name: "gives up after attempts", failures: []error{limited, limited, limited}, attempts: 3, calls: 3, wantErr: true},
+		{name: "does not retry auth failures", failures: []error{errors.NewTencentCloudSDKError("AuthFailure.SignatureFailure", "bad signature", "id")}, attempts: 3, calls: 1, wantErr: true},
+		{name: "retries 503", failures: []error{httpStatusError("503 Service Unavailable")}, attempts: 3, calls: 2},
+		{name: "retries 429", failures: []error{httpStatusError("429 Too Many Requests")}, attempts: 3, calls: 2},
+		{name: "does not retry 403", failures: []error{httpStatusError("403 Forbidden")}, attempts: 3, calls: 1, wantErr: true},
+		{name: "does not retry 400", failures: []error{httpStatusError("400 Bad Request")}, attempts: 3, calls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			success := windowResponse(start)
+			var calledAt []time.Time
+			client := &fakeClient{respond: func(n int, request *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, error) {
+				calledAt = append(calledAt, time.Now())
+				if n < len(tt.failures) {
+					return nil, tt.failures[n]
+				}
+				return success(n, request)
+			}}
+			baseDelay := 5 * time.Millisecond
+			c := New(client, Config{ClusterID: "cls-test", Metrics: []string{"Metric00"}, RetryAttempts: tt.attempts, RetryBaseDelay: baseDelay})
+			_, err := c.CollectWorkload(context.Background(), Workload{Namespace: "default", Kind: "Deployment", Name: "api"}, start, start.Add(time.Hour))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CollectWorkload error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(client.requests) != tt.calls {
+				t.Fatalf("got %d calls, want %d", len(client.requests), tt.calls)
+			}
+			// 每次重试前的等待时间翻倍
+			delay := baseDelay
+			for i := 1; i < len(calledAt); i++ {
+				if waited := calledAt[i].Sub(calledAt[i-1]); waited < delay {
+					t.Errorf("retry %d waited %s, want at least %s", i, waited, delay)
+				}
+				delay *= 2
+			}
+		})
+	}
+}
+
+// groupedResponse 按 workload_kind、workload_name 分组的响应，old-api 只有 null 数据点，cron 的 kind 不在查询范围内
+const groupedResponse = `{
+  "Response": {
+    "Data": [
+      {
+        "MetricName": "K8sWorkloadRateCpuCoreUsedRequestMax",
+        "Points": [
+          {"Dimensions": [{"Name": "workload_kind", "Value": "deployment"}, {"Name": "workload_name", "Value": "web"}], "Values": [{"Timestamp": 1721260800, "Value": 5}]},
+          {"Dimensions": [{"Name": "workload_kind", "Value": "deployment"}, {"Name": "workload_name", "Value": "api"}], "Values": [{"Timestamp": 1721260800, "Value": 10}]},
+          {"Dimensions": [{"Name": "workload_kind", "Value": "deployment"}, {"Name": "workload_name", "Value": "old-api"}], "Values": [{"Timestamp": 1721260800, "Value": null}]},
+          {"Dimensions": [{"Name": "workload_kind", "Value": "CronJob"}, {"Name": "workload_name", "Value": "cron"}], "Values": [{"Timestamp": 1721260800, "Value": 1}]}
+        ]
+      }
+    ],
+    "RequestId": "grouped-request-id"
+  }
+}`
+
+func TestWorkloads(t *testing.T) {
+	client := &fakeClient{respond: func(int, *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, error) {
+		return parseResponse(t, groupedResponse), nil
+	}}
+	c := New(client, Config{
+		ClusterID:  "cls-test",
+		Metrics:    []string{"K8sWorkloadRateCpuCoreUsedRequestMax", "K8sWorkloadRateMemWorkingSetBytesRequestMax"},
+		KindValues: map[string]string{"Deployment": "deployment"},
+	})
+
+	start := time.Date(2024, 7, 18, 0, 0, 0, 0, time.UTC)
+	workloads, err := c.Workloads(context.Background(), "default", []string{"Deployment"}, start, start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("Workloads: %v", err)
+	}
+	want := []Workload{
+		{Namespace: "default", Kind: "Deployment", Name: "api"},
+		{Namespace: "default", Kind: "Deployment", Name: "web"},
+	}
+	if !reflect.DeepEqual(workloads, want) {
+		t.Errorf("Workloads = %v, want %v", workloads, want)
+	}
+
+	request := client.requests[0]
+	if got := fmt.Sprint(common.StringValues(request.GroupBys)); got != "[workload_kind workload_name]" {
+		t.Errorf("GroupBys = %s", got)
+	}
+	if got := fmt.Sprint(common.StringValues(request.MetricNames)); got != "[K8sWorkloadRateCpuCoreUsedRequestMax]" {
+		t.Errorf("MetricNames = %s, want only the first metric", got)
+	}
+	for _, condition := range request.Conditions {
+		if *condition.Key == "workload_name" || *condition.Key == "workload_kind" {
+			t.Errorf("unexpected condition on %s", *condition.Key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+	monitor "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/monitor/v20180724"
+	"k8s.io/klog/v2"
+)
+
+// retryablePrefixes 可以重试的错误码前缀，包括限频和服务端临时错误
+var retryablePrefixes = []string{
+	"RequestLimitExceeded",
+	"InternalError",
+	"ClientError.NetworkError",
+}
+
+// httpStatusPattern 匹配 SDK 在 ClientError.HttpStatusCodeError 的消息中给出的 HTTP 状态码
+var httpStatusPattern = regexp.MustCompile(`http status code: (\d{3})`)
+
+// isRetryable 判断错误是否可以重试，鉴权失败等错误立即返回。非 SDK 错误（如网络错误）也会重试，
+// 非 200 的 HTTP 响应只重试 429 和 5xx，400、401、403 等重试也不会成功
+func isRetryable(err error) bool {
+	sdkErr, ok := err.(*errors.TencentCloudSDKError)
+	if !ok {
+		return true
+	}
+	if sdkErr.GetCode() == "ClientError.HttpStatusCodeError" {
+		m := httpStatusPattern.FindStringSubmatch(sdkErr.GetMessage())
+		if m == nil {
+			return false
+		}
+		status, _ := strconv.Atoi(m[1])
+		return status == http.StatusTooManyRequests || status >= 500
+	}
+	for _, prefix := range retryablePrefixes {
+		if strings.HasPrefix(sdkErr.GetCode(), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeStatisticData 调用 DescribeStatisticData，遇到限频和临时错误时按指数退避重试，
+// 最多尝试 RetryAttempts 次，返回实际重试的次数。配置了 Limiter 时每次调用前等待令牌
+func (c *Collector) describeStatisticData(ctx context.Context, request *monitor.DescribeStatisticDataRequest) (*monitor.DescribeStatisticDataResponse, int, error) {
+	delay := c.config.RetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		// 重试同样计入限速
+		if c.config.Limiter != nil {
+			if err := c.config.Limiter.Wait(ctx); err != nil {
+				return nil, attempt - 1, err
+			}
+		}
+		response, err := c.client.DescribeStatisticDataWithContext(ctx, request)
+		if err == nil || attempt >= c.config.RetryAttempts || !isRetryable(err) || ctx.Err() != nil {
+			return response, attempt - 1, err
+		}
+
+		klog.Warningf("DescribeStatisticData failed (attempt %d/%d), retrying in %s: %v", attempt, c.config.RetryAttempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, attempt - 1, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
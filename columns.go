@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// builtinColumns columns 中可以使用的工作负载属性列及其默认列名
+var builtinColumns = map[string]string{
+	"cluster":        "Cluster",
+	"namespace":      "Namespace",
+	"kind":           "Kind",
+	"workload":       "Workload",
+	"container":      "Container",
+	"pod":            "Pod",
+	"replicas":       "Replicas",
+	"flapping":       "Flapping",
+	"recommendation": "Recommendation",
+	"comparison":     "Comparison",
+	"error":          "Error",
+	"collectedAt":    collectedAtColumn,
+}
+
+// collectedAtColumn CSV 报告中记录生成时间的列，-merge 读取该列作为报告的采集时间
+const collectedAtColumn = "Collected At"
+
+// validateColumns 检查 columns 中的每一列都是属性列、标签或本次输出的指标列，
+// 指标列可以写指标名、派生列名或默认列名
+func validateColumns(columns []metricColumn) error {
+	var problems []error
+	for i, c := range columns {
+		if c.Name == "" {
+			problems = append(problems, fmt.Errorf("columns[%d] requires a name, e.g. - namespace", i))
+			continue
+		}
+		if _, ok := resolveColumn(c.Name); !ok {
+			problems = append(problems, fmt.Errorf("columns[%d]: unknown column %q, expected one of cluster, namespace, kind, workload, container, pod, replicas, flapping, recommendation, comparison, error, collectedAt, a label key or an output metric column", i, c.Name))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// resolveColumn 返回 columns 中一列的默认列名和取值方法
+func resolveColumn(name string) (selectedColumn, bool) {
+	if name == "collectedAt" {
+		return selectedColumn{header: collectedAtColumn, value: func(r *report, result workloadResult) string { return r.collectedAt() }}, true
+	}
+	if header, ok := builtinColumns[name]; ok {
+		return selectedColumn{header: header, value: func(r *report, result workloadResult) string { return builtinValue(name, result) }}, true
+	}
+	if _, ok := config.Labels[name]; ok {
+		return selectedColumn{header: name, value: func(r *report, result workloadResult) string { return r.Labels[name] }}, true
+	}
+	for _, m := range outputColumns() {
+		if m.Name == name || m.Header == name {
+			metric := m.Name
+			return selectedColumn{header: m.Header, value: func(r *report, result workloadResult) string { return formatValue(result.Values, metric) }}, true
+		}
+	}
+	return selectedColumn{}, false
+}
+
+// selectedColumn 按 columns 输出的一列
+type selectedColumn struct {
+	header string
+	value  func(r *report, result workloadResult) string
+}
+
+func builtinValue(name string, result workloadResult) string {
+	switch name {
+	case "cluster":
+		if result.Cluster != "" {
+			return result.Cluster
+		}
+		return config.ClusterID
+	case "namespace":
+		return result.Namespace
+	case "kind":
+		return result.Kind
+	case "workload":
+		return result.Name
+	case "container":
+		return result.Container
+	case "pod":
+		return result.Pod
+	case "replicas":
+		return strconv.Itoa(int(result.Replicas))
+	case "flapping":
+		return strconv.FormatBool(result.Flapping)
+	case "recommendation":
+		return result.Recommendation
+	case "comparison":
+		return result.Comparison
+	case "error":
+		return errorMessage(result)
+	}
+	return ""
+}
+
+// collectedAt 返回 Collected At 列的值
+func (r *report) collectedAt() string {
+	return r.CollectedAt.UTC().Format(time.RFC3339)
+}
+
+// configuredColumns 返回 columns 对应的表头与每行的值，未配置 columns 时使用默认的表头和行，并在最后增加 Collected At 列
+func (r *report) configuredColumns() (header []string, row func(workloadResult) []string) {
+	if len(config.Columns) == 0 {
+		return append(r.header(), collectedAtColumn), func(result workloadResult) []string {
+			return append(r.row(result), r.collectedAt())
+		}
+	}
+	var columns []selectedColumn
+	for _, c := range config.Columns {
+		column, _ := resolveColumn(c.Name)
+		// 直接写列名时 Header 与 Name 相同，使用默认列名
+		if c.Header != c.Name {
+			column.header = c.Header
+		}
+		header = append(header, column.header)
+		columns = append(columns, column)
+	}
+	return header, func(result workloadResult) []string {
+		var values []string
+		for _, c := range columns {
+			values = append(values, c.value(r, result))
+		}
+		return values
+	}
+}
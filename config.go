@@ -0,0 +1,490 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/regions"
+	"gopkg.in/yaml.v2"
+)
+
+type Config struct {
+	Region    string `yaml:"region"`
+	ClusterID string `yaml:"clusterID"`
+	// Namespaces 需要扫描的命名空间，可以写成单个字符串或列表
+	Namespaces namespaceList `yaml:"namespace"`
+	// NamespacesAlias namespace 的别名，两者同时配置时合并
+	NamespacesAlias namespaceList `yaml:"namespaces"`
+	SecretID        string        `yaml:"secretID"`
+	SecretKey       string        `yaml:"secretKey"`
+	// SessionToken STS 临时凭证的 token，使用永久密钥时留空
+	SessionToken string `yaml:"sessionToken"`
+	// MonitorNamespace 云监控命名空间，默认为 QCE/TKE2
+	MonitorNamespace string `yaml:"monitorNamespace"`
+	// Metrics 需要采集的指标及其列名，默认见 defaultMetricColumns
+	Metrics []metricColumn `yaml:"metrics"`
+	// Endpoint 云监控 API 地址，http:// 前缀表示使用 HTTP，便于对接本地的 fake server
+	Endpoint string `yaml:"endpoint"`
+	// Proxy 请求云监控 API 使用的 HTTP 代理，为空时读取 HTTPS_PROXY、NO_PROXY 等环境变量
+	Proxy string `yaml:"proxy"`
+	// Regions 除 SDK 内置地域以外允许使用的地域，用于专有云等场景
+	Regions []string `yaml:"regions"`
+	// WorkloadKinds 需要采集的工作负载类型，默认只采集 Deployment
+	WorkloadKinds []string `yaml:"workloadKinds"`
+	// CustomWorkloadKinds 通过 dynamic client 列出的自定义工作负载类型，需要同时加入 workloadKinds
+	CustomWorkloadKinds []CustomWorkloadKind `yaml:"customWorkloadKinds"`
+	// WorkloadKindValues 覆盖 Kubernetes kind 到 workload_kind 维度值的映射
+	WorkloadKindValues map[string]string `yaml:"workloadKindValues"`
+	// ReplicaMetric -replicas 查询峰值副本数使用的工作负载 Pod 数量指标，QCE/TKE2 中没有这样的指标，为空时只输出期望副本数
+	ReplicaMetric string `yaml:"replicaMetric"`
+	// GPU 与 -gpu 相同，采集 GPU 指标
+	GPU bool `yaml:"gpu"`
+	// ContainerMetrics 覆盖 -containers 使用的工作负载指标到容器维度指标的映射
+	ContainerMetrics map[string]string `yaml:"containerMetrics"`
+	// PodMetrics 覆盖 -granularity pod 使用的工作负载指标到 Pod 维度指标的映射
+	PodMetrics map[string]string `yaml:"podMetrics"`
+	// AlertThresholds 每一列的告警阈值，key 为指标名或派生列名，超过阈值的工作负载打印告警
+	AlertThresholds map[string]float64 `yaml:"alertThresholds"`
+	// Prices -cost 使用的单价，key 为地域，default 用于没有单独配置的地域
+	Prices map[string]UnitPrice `yaml:"prices"`
+	// Expressions 根据指标统计值计算的自定义列
+	Expressions []Expression `yaml:"expressions"`
+	// Labels 附加到每一行的常量列
+	Labels map[string]string `yaml:"labels"`
+	// Columns CSV 报告输出的列及其顺序，可以写成单个列名或 {name, header}，为空时输出所有列
+	Columns []metricColumn `yaml:"columns"`
+	// Clusters 多集群配置，设置后忽略顶层的 clusterID，报告中增加 Cluster 列
+	Clusters []ClusterConfig `yaml:"clusters"`
+	// DiscoverClusters 通过 TKE API 发现集群，设置后不需要配置 clusterID 和 clusters
+	DiscoverClusters *ClusterDiscovery `yaml:"discoverClusters"`
+}
+
+// UnitPrice 一个地域的资源单价，货币单位由使用者决定
+type UnitPrice struct {
+	// CPUCoreHour 每核每小时的价格
+	CPUCoreHour float64 `yaml:"cpuCoreHour"`
+	// MemoryGBHour 每 GiB 内存每小时的价格
+	MemoryGBHour float64 `yaml:"memoryGBHour"`
+}
+
+// ClusterConfig 多集群配置中的一个集群，未设置的地域和凭证使用顶层配置
+type ClusterConfig struct {
+	// Name 报告中 Cluster 列的值，默认为 clusterID
+	Name      string `yaml:"name"`
+	ClusterID string `yaml:"clusterID"`
+	Region    string `yaml:"region"`
+	// Kubeconfig 访问该集群使用的 kubeconfig 文件，默认使用 -kubeconfig
+	Kubeconfig string `yaml:"kubeconfig"`
+	// Context kubeconfig 中对应集群的 context，默认使用 current-context
+	Context      string `yaml:"context"`
+	SecretID     string `yaml:"secretID"`
+	SecretKey    string `yaml:"secretKey"`
+	SessionToken string `yaml:"sessionToken"`
+}
+
+// clusters 返回本次运行需要采集的集群，没有配置 clusters 时为顶层配置对应的单个集群
+func (c Config) clusters() []ClusterConfig {
+	if len(c.Clusters) == 0 {
+		return []ClusterConfig{{
+			ClusterID:    c.ClusterID,
+			Region:       c.Region,
+			SecretID:     c.SecretID,
+			SecretKey:    c.SecretKey,
+			SessionToken: c.SessionToken,
+		}}
+	}
+
+	var clusters []ClusterConfig
+	for _, cl := range c.Clusters {
+		if cl.Name == "" {
+			cl.Name = cl.ClusterID
+		}
+		if cl.Region == "" {
+			cl.Region = c.Region
+		}
+		if cl.SecretID == "" && cl.SecretKey == "" {
+			cl.SecretID, cl.SecretKey, cl.SessionToken = c.SecretID, c.SecretKey, c.SessionToken
+		}
+		clusters = append(clusters, cl)
+	}
+	return clusters
+}
+
+// namespaceList 兼容旧配置中 namespace 为单个字符串的写法
+type namespaceList []string
+
+func (n *namespaceList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*n = namespaceList{single}
+		return nil
+	}
+	return unmarshal((*[]string)(n))
+}
+
+// defaultMonitorNamespace 未配置 monitorNamespace 时使用的云监控命名空间
+const defaultMonitorNamespace = "QCE/TKE2"
+
+// metricColumn 描述一个需要采集的监控指标及其在报告中的列名
+type metricColumn struct {
+	Name   string `yaml:"name"`
+	Header string `yaml:"header"`
+	// Stat 只用于 metrics，逗号分隔的统计方式，覆盖该指标的 -stat，例如重启次数取 max、网络流量取 avg
+	Stat string `yaml:"stat"`
+}
+
+// UnmarshalYAML 支持直接写指标名，此时列名与指标名相同
+func (m *metricColumn) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*m = metricColumn{Name: name, Header: name}
+		return nil
+	}
+
+	type plain metricColumn
+	if err := unmarshal((*plain)(m)); err != nil {
+		return err
+	}
+	if m.Header == "" {
+		m.Header = m.Name
+	}
+	return nil
+}
+
+// defaultMetricColumns 未配置 metrics 时按报告列顺序采集的指标
+var defaultMetricColumns = []metricColumn{
+	{Name: "K8sWorkloadRateCpuCoreUsedRequestMax", Header: "CPU Usage Max (percent)"},
+	{Name: "K8sWorkloadRateMemWorkingSetBytesRequestMax", Header: "Memory Usage Max (percent)"},
+	{Name: "K8sWorkloadRateCpuCoreUsedCluster", Header: "CPU Usage Max (% of cluster)"},
+	{Name: "K8sWorkloadRateMemUsageBytesCluster", Header: "Memory Usage Max (% of cluster)"},
+}
+
+// defaultWorkloadKindValues 各监控命名空间下 Kubernetes kind 对应的 workload_kind 维度值
+var defaultWorkloadKindValues = map[string]map[string]string{
+	"QCE/TKE2": {
+		"Deployment":  "Deployment",
+		"StatefulSet": "StatefulSet",
+		"DaemonSet":   "DaemonSet",
+		"Job":         "Job",
+		"CronJob":     "CronJob",
+	},
+}
+
+// defaultContainerMetrics 各监控命名空间下工作负载指标对应的容器维度指标，没有对应指标的列在容器行中输出 -empty-value
+var defaultContainerMetrics = map[string]map[string]string{
+	"QCE/TKE2": {
+		"K8sWorkloadRateCpuCoreUsedRequestMax":        "K8sContainerRateCpuCoreUsedRequest",
+		"K8sWorkloadRateMemWorkingSetBytesRequestMax": "K8sContainerRateMemNoCacheRequest",
+		"K8sWorkloadRateCpuCoreUsedLimitMax":          "K8sContainerRateCpuCoreUsedLimit",
+		"K8sWorkloadRateMemWorkingSetBytesLimitMax":   "K8sContainerRateMemNoCacheLimit",
+		"K8sWorkloadCpuCoreUsed":                      "K8sContainerCpuCoreUsed",
+		"K8sWorkloadMemWorkingSetBytes":               "K8sContainerMemNoCacheBytes",
+	},
+}
+
+// defaultPodMetrics 各监控命名空间下工作负载指标对应的 Pod 维度指标，没有对应指标的列在 Pod 行中输出 -empty-value
+var defaultPodMetrics = map[string]map[string]string{
+	"QCE/TKE2": {
+		"K8sWorkloadRateCpuCoreUsedRequestMax":        "K8sPodRateCpuCoreUsedRequest",
+		"K8sWorkloadRateMemWorkingSetBytesRequestMax": "K8sPodRateMemNoCacheRequest",
+		"K8sWorkloadRateCpuCoreUsedLimitMax":          "K8sPodRateCpuCoreUsedLimit",
+		"K8sWorkloadRateMemWorkingSetBytesLimitMax":   "K8sPodRateMemNoCacheLimit",
+		"K8sWorkloadCpuCoreUsed":                      "K8sPodCpuCoreUsed",
+		"K8sWorkloadMemWorkingSetBytes":               "K8sPodMemNoCacheBytes",
+	},
+}
+
+// knownRegions SDK 内置的地域，region 不在其中且不在配置的 regions 中时校验失败
+var knownRegions = []string{
+	regions.Bangkok, regions.Beijing, regions.Chengdu, regions.Chongqing,
+	regions.Guangzhou, regions.GuangzhouOpen, regions.HongKong, regions.Jakarta,
+	regions.Mumbai, regions.Seoul, regions.Shanghai, regions.Nanjing,
+	regions.ShanghaiFSI, regions.ShenzhenFSI, regions.Singapore, regions.Tokyo,
+	regions.Frankfurt, regions.Moscow, regions.Ashburn, regions.SiliconValley,
+	regions.Toronto, regions.SaoPaulo,
+}
+
+func isKnownRegion(config Config, region string) bool {
+	for _, r := range append(append([]string{}, knownRegions...), config.Regions...) {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// validateEndpoint 检查 endpoint 为 host 或 host:port，可以带 http:// 或 https:// 前缀，不能包含路径
+func validateEndpoint(endpoint string) error {
+	_, host := parseEndpoint(endpoint)
+	u, err := url.Parse("https://" + host)
+	if err != nil || u.Host == "" || u.Host != host {
+		return fmt.Errorf("invalid endpoint %q, expected host[:port] with an optional http:// or https:// prefix", endpoint)
+	}
+	return nil
+}
+
+// configSources 记录每个配置项的来源，key 为 yaml 字段名
+var configSources = map[string]string{}
+
+// loadConfig 读取并解析配置文件，同时记录每个字段的来源
+func loadConfig(path string) (Config, error) {
+	var c Config
+
+	// 以 ConfigMap 或 Secret 挂载时 path 为目录，读取其中的 config.yaml
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "config.yaml")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("Error reading config file: %v", err)
+	}
+
+	// 未知的字段报错，避免 clusterId 这样的拼写错误被静默忽略
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return c, fmt.Errorf("Error unmarshaling YAML %s: %v%s", path, err, unknownFieldHints(err))
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return c, fmt.Errorf("Error unmarshaling YAML: %v", err)
+	}
+	for key := range raw {
+		configSources[key] = "file " + path
+	}
+
+	if len(c.NamespacesAlias) > 0 {
+		c.Namespaces = append(c.Namespaces, c.NamespacesAlias...)
+		c.NamespacesAlias = nil
+		configSources["namespace"] = "file " + path
+	}
+
+	// 配置文件中没有凭证时从环境变量读取，避免把密钥保存在明文文件中
+	for key, field := range map[string]*string{
+		"secretID":     &c.SecretID,
+		"secretKey":    &c.SecretKey,
+		"sessionToken": &c.SessionToken,
+	} {
+		env := credentialEnvs[key]
+		if *field == "" && os.Getenv(env) != "" {
+			*field = os.Getenv(env)
+			configSources[key] = "env " + env
+		}
+	}
+
+	if c.MonitorNamespace == "" {
+		c.MonitorNamespace = defaultMonitorNamespace
+		configSources["monitorNamespace"] = "default"
+	}
+	if len(c.Metrics) == 0 {
+		c.Metrics = defaultMetricColumns
+		configSources["metrics"] = "default"
+	}
+	if len(c.WorkloadKinds) == 0 {
+		c.WorkloadKinds = []string{"Deployment"}
+		configSources["workloadKinds"] = "default"
+	}
+
+	return c, nil
+}
+
+// credentialEnvs 配置文件中没有凭证时读取的环境变量
+var credentialEnvs = map[string]string{
+	"secretID":     "TENCENTCLOUD_SECRET_ID",
+	"secretKey":    "TENCENTCLOUD_SECRET_KEY",
+	"sessionToken": "TENCENTCLOUD_SESSION_TOKEN",
+}
+
+var unknownFieldPattern = regexp.MustCompile(`field (\S+) not found in type`)
+
+// unknownFieldHints 为严格解析报告的未知字段给出大小写不同的正确字段名，没有可提示的字段时返回空字符串
+func unknownFieldHints(err error) string {
+	keys := map[string]bool{}
+	yamlKeys(reflect.TypeOf(Config{}), keys)
+
+	var hints []string
+	for _, m := range unknownFieldPattern.FindAllStringSubmatch(err.Error(), -1) {
+		for key := range keys {
+			if strings.EqualFold(key, m[1]) {
+				hints = append(hints, fmt.Sprintf("%s should be %s", m[1], key))
+			}
+		}
+	}
+	if len(hints) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(hints, ", ") + ")"
+}
+
+// yamlKeys 收集 t 及其嵌套的结构体、切片和 map 元素中所有的 yaml key
+func yamlKeys(t reflect.Type, keys map[string]bool) {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		yamlKeys(t.Elem(), keys)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if name != "" && name != "-" {
+				keys[name] = true
+			}
+			yamlKeys(f.Type, keys)
+		}
+	}
+}
+
+// secretFields 在 -show-config 中需要脱敏的字段
+var secretFields = map[string]bool{
+	"secretID":     true,
+	"secretKey":    true,
+	"sessionToken": true,
+}
+
+// showConfig 打印生效的配置以及每个字段的来源，敏感字段脱敏
+func showConfig(c Config) {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		field := v.Field(i).Interface()
+		// 集群中的凭证同样需要脱敏
+		if clusters, ok := field.([]ClusterConfig); ok {
+			redacted := make([]ClusterConfig, len(clusters))
+			for j, cl := range clusters {
+				if cl.SecretID != "" {
+					cl.SecretID = redact(cl.SecretID)
+				}
+				if cl.SecretKey != "" {
+					cl.SecretKey = redact(cl.SecretKey)
+				}
+				if cl.SessionToken != "" {
+					cl.SessionToken = redact(cl.SessionToken)
+				}
+				redacted[j] = cl
+			}
+			field = redacted
+		}
+		value := fmt.Sprintf("%v", field)
+		if secretFields[key] && value != "" {
+			value = redact(value)
+		}
+
+		source, ok := configSources[key]
+		if !ok {
+			source = "unset"
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s (%s)\n", key, value, source)
+	}
+}
+
+// redact 只保留最后 4 个字符
+func redact(s string) string {
+	if len(s) <= 4 {
+		return "****"
+	}
+	return "****" + s[len(s)-4:]
+}
+
+// validate 检查配置，-all-namespaces 时不要求配置 namespace
+func validate(config Config) error {
+	var problems []error
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	for i, cl := range config.clusters() {
+		// 多集群配置的错误信息中指出是哪一个集群
+		prefix := ""
+		if len(config.Clusters) > 0 {
+			prefix = fmt.Sprintf("clusters[%d].", i)
+		}
+		if cl.Region == "" {
+			// discoverClusters 指定了地域时，发现的集群使用各自的地域
+			if config.DiscoverClusters == nil || len(config.DiscoverClusters.Regions) == 0 {
+				problem("%sregion is required, e.g. region: ap-guangzhou", prefix)
+			}
+		} else if !isKnownRegion(config, cl.Region) {
+			problem("%sregion %q is unknown, e.g. region: ap-guangzhou, add it to regions if it is a private region", prefix, cl.Region)
+		}
+		if cl.ClusterID == "" && config.DiscoverClusters == nil {
+			problem("%sclusterID is required, e.g. clusterID: cls-xxxxxxxx", prefix)
+		}
+		if cl.SecretID == "" {
+			problem("%ssecretID is required, set it in the config or %s", prefix, credentialEnvs["secretID"])
+		}
+		if cl.SecretKey == "" {
+			problem("%ssecretKey is required, set it in the config or %s", prefix, credentialEnvs["secretKey"])
+		}
+	}
+	if d := config.DiscoverClusters; d != nil {
+		if len(config.Clusters) > 0 {
+			problem("discoverClusters cannot be used with clusters")
+		}
+		for i, r := range d.Regions {
+			if !isKnownRegion(config, r) {
+				problem("discoverClusters.regions[%d]: region %q is unknown, e.g. ap-guangzhou, add it to regions if it is a private region", i, r)
+			}
+		}
+		for i, pattern := range d.Names {
+			if _, err := path.Match(pattern, ""); err != nil {
+				problem("discoverClusters.names[%d]: invalid pattern %q, e.g. prod-*", i, pattern)
+			}
+		}
+	}
+	if config.Endpoint != "" {
+		if err := validateEndpoint(config.Endpoint); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if len(config.Namespaces) == 0 && !allNamespaces {
+		problem("namespace is required, e.g. namespace: default, or pass -all-namespaces")
+	}
+	for i, ns := range config.Namespaces {
+		if ns == "" {
+			problem("namespace[%d] must not be empty", i)
+		}
+	}
+	seen := map[string]bool{}
+	for i, m := range config.Metrics {
+		if m.Name == "" {
+			problem("metrics[%d] requires a name, e.g. - K8sWorkloadCpuCoreUsed", i)
+			continue
+		}
+		if seen[m.Name] {
+			problem("metrics[%d]: metric %s is listed more than once", i, m.Name)
+		}
+		if m.Stat != "" {
+			if _, err := parseStats(m.Stat); err != nil {
+				problem("metrics[%d]: %v, e.g. stat: max,avg", i, err)
+			}
+		}
+		seen[m.Name] = true
+	}
+	for _, kind := range config.WorkloadKinds {
+		if _, ok := customWorkloadKind(config, kind); !ok && !isSupportedWorkloadKind(kind) {
+			problem("workloadKinds: unsupported workload kind %q, supported kinds are %v, other kinds need an entry in customWorkloadKinds", kind, supportedWorkloadKinds)
+		}
+	}
+	for i, k := range config.CustomWorkloadKinds {
+		if k.Kind == "" || k.Version == "" || k.Resource == "" {
+			problem("customWorkloadKinds[%d] requires kind, version and resource, e.g. {kind: Rollout, group: argoproj.io, version: v1alpha1, resource: rollouts}", i)
+		} else if isSupportedWorkloadKind(k.Kind) {
+			problem("customWorkloadKinds[%d]: kind %q is already supported", i, k.Kind)
+		}
+	}
+	for i, e := range config.Expressions {
+		if e.Name == "" || e.Expr == "" {
+			problem("expressions[%d] requires both name and expr, e.g. {name: CPU Headroom, expr: 100 - K8sWorkloadRateCpuCoreUsedRequestMax}", i)
+		} else if isMetricColumn(e.Name) {
+			problem("expressions[%d]: name %q conflicts with a metric name", i, e.Name)
+		}
+	}
+	return errors.Join(problems...)
+}
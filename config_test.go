@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coderwangke/tke-workload-metrics/collector"
+)
+
+// kindConditionTest workload_kind 条件的一个测试用例，overrides 为配置中的 workloadKindValues
+type kindConditionTest struct {
+	name             string
+	monitorNamespace string
+	kinds            []string
+	overrides        map[string]string
+	kind             string
+	want             string
+}
+
+func TestWorkloadKindCondition(t *testing.T) {
+	tests := []kindConditionTest{
+		{name: "QCE/TKE2 Deployment", monitorNamespace: "QCE/TKE2", kinds: []string{"Deployment"}, kind: "Deployment", want: "Deployment"},
+		{name: "QCE/TKE2 CronJob", monitorNamespace: "QCE/TKE2", kinds: []string{"Deployment", "CronJob"}, kind: "CronJob", want: "CronJob"},
+		{name: "unknown monitor namespace uses the kind", monitorNamespace: "QCE/CUSTOM", kinds: []string{"Deployment"}, kind: "Deployment", want: "Deployment"},
+		{name: "override from config", monitorNamespace: "QCE/TKE2", kinds: []string{"Deployment"}, overrides: map[string]string{"Deployment": "deployment"}, kind: "Deployment", want: "deployment"},
+		{name: "override outside workloadKinds for -discover-pods", monitorNamespace: "QCE/TKE2", kinds: []string{"Deployment"}, overrides: map[string]string{"Rollout": "rollout"}, kind: "Rollout", want: "rollout"},
+		{name: "override in another monitor namespace", monitorNamespace: "QCE/CUSTOM", kinds: []string{"StatefulSet"}, overrides: map[string]string{"StatefulSet": "statefulset"}, kind: "StatefulSet", want: "statefulset"},
+	}
+	// 每个监控命名空间的每个默认映射
+	for namespace, values := range defaultWorkloadKindValues {
+		for kind, value := range values {
+			tests = append(tests, kindConditionTest{name: "default " + namespace + " " + kind, monitorNamespace: namespace, kinds: []string{kind}, kind: kind, want: value})
+		}
+	}
+	saved := config
+	defer func() { config = saved }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = Config{MonitorNamespace: tt.monitorNamespace, WorkloadKinds: tt.kinds, WorkloadKindValues: tt.overrides}
+			col := collector.New(nil, collector.Config{
+				ClusterID:        "cls-test",
+				MonitorNamespace: tt.monitorNamespace,
+				Metrics:          []string{"K8sWorkloadRateCpuCoreUsedRequestMax"},
+				KindValues:       collectorKindValues(),
+			})
+			start := time.Date(2024, 7, 18, 0, 0, 0, 0, time.UTC)
+			requests := col.Plan(collector.Workload{Namespace: "default", Kind: tt.kind, Name: "api"}, start, start.Add(time.Hour))
+			if len(requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(requests))
+			}
+			var got []string
+			for _, c := range requests[0].Conditions {
+				if *c.Key == "workload_kind" {
+					for _, v := range c.Value {
+						got = append(got, *v)
+					}
+				}
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("workload_kind condition = %v, want [%s]", got, tt.want)
+			}
+		})
+	}
+}
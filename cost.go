@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+const estimatedCostColumn = "Estimated Cost"
+
+// defaultPriceRegion 没有为集群地域单独配置单价时使用的 prices key
+const defaultPriceRegion = "default"
+
+// unitPrice 返回地域的单价，没有单独配置时使用 default
+func unitPrice(region string) (UnitPrice, bool) {
+	if p, ok := config.Prices[region]; ok {
+		return p, true
+	}
+	p, ok := config.Prices[defaultPriceRegion]
+	return p, ok
+}
+
+// validatePrices 检查每个集群的地域都有对应的单价
+func validatePrices(clusters []ClusterConfig) error {
+	for _, cl := range clusters {
+		if _, ok := unitPrice(cl.Region); !ok {
+			return fmt.Errorf("no price for region %s, add prices.%s or prices.%s to the config", cl.Region, cl.Region, defaultPriceRegion)
+		}
+	}
+	return nil
+}
+
+// costColumns 返回 -cost 增加的列
+func costColumns() []metricColumn {
+	return []metricColumn{{Name: estimatedCostColumn, Header: estimatedCostColumn}}
+}
+
+// estimateCost 按单个 Pod 的绝对用量、副本数和时间范围估算成本，必须在 applyResources 之后调用。
+// 采集的资源中任意一个没有用量时不输出，避免只算一部分的成本被误认为完整
+func estimateCost(r *workloadResult, region string, hours float64) {
+	price, ok := unitPrice(region)
+	if !ok {
+		return
+	}
+	active := map[string]bool{}
+	for _, c := range resourceColumns() {
+		active[c.Name] = true
+	}
+
+	var cost float64
+	known := false
+	for _, c := range []struct {
+		column string
+		price  float64
+		// unit 单价对应的用量单位，内存按 GiB 计价
+		unit float64
+	}{
+		{column: "CPU Usage Max per Pod (cores)", price: price.CPUCoreHour, unit: 1},
+		{column: "Memory Usage Max per Pod", price: price.MemoryGBHour, unit: 1 << 30},
+	} {
+		if !active[c.column] {
+			continue
+		}
+		usage, ok := r.Values[c.column]
+		if !ok {
+			return
+		}
+		cost += usage / c.unit * c.price
+		known = true
+	}
+	if known {
+		r.Values[estimatedCostColumn] = cost * float64(r.Replicas) * hours
+	}
+}
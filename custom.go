@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CustomWorkloadKind 通过 dynamic client 列出的自定义工作负载类型，例如 Argo Rollouts 的 Rollout。
+// 对象需要和 Deployment 一样在 spec.template 中包含 Pod 模板，副本数取自 spec.replicas
+type CustomWorkloadKind struct {
+	// Kind workloadKinds 和报告中使用的类型名，也是默认的 workload_kind 维度值
+	Kind     string `yaml:"kind"`
+	Group    string `yaml:"group"`
+	Version  string `yaml:"version"`
+	Resource string `yaml:"resource"`
+}
+
+func (k CustomWorkloadKind) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: k.Group, Version: k.Version, Resource: k.Resource}
+}
+
+// customWorkloadKind 返回配置中 kind 对应的自定义类型
+func customWorkloadKind(c Config, kind string) (CustomWorkloadKind, bool) {
+	for _, k := range c.CustomWorkloadKinds {
+		if k.Kind == kind {
+			return k, true
+		}
+	}
+	return CustomWorkloadKind{}, false
+}
+
+// clusterClients 访问一个集群的 clientset 和 dynamic client
+type clusterClients struct {
+	kubernetes.Interface
+	Dynamic dynamic.Interface
+}
+
+// listCustomWorkloads 按 opts.Limit 分页列出一种自定义工作负载，直到 Continue 为空
+func listCustomWorkloads(ctx context.Context, client dynamic.Interface, namespace string, kind CustomWorkloadKind, opts metav1.ListOptions) ([]workload, error) {
+	var workloads []workload
+	for {
+		list, err := client.Resource(kind.gvr()).Namespace(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			w, err := newCustomWorkload(kind.Kind, item)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", kind.Kind, item.GetName(), err)
+			}
+			workloads = append(workloads, w)
+		}
+		if list.GetContinue() == "" {
+			return workloads, nil
+		}
+		opts.Continue = list.GetContinue()
+	}
+}
+
+func newCustomWorkload(kind string, item unstructured.Unstructured) (workload, error) {
+	w := workload{Kind: kind, Replicas: 1}
+	if meta, ok, _ := unstructured.NestedMap(item.Object, "metadata"); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(meta, &w.ObjectMeta); err != nil {
+			return w, fmt.Errorf("parse metadata: %v", err)
+		}
+	}
+
+	if tmpl, ok, _ := unstructured.NestedMap(item.Object, "spec", "template"); ok {
+		var template corev1.PodTemplateSpec
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(tmpl, &template); err != nil {
+			return w, fmt.Errorf("parse spec.template: %v", err)
+		}
+		w.Resources = newPodResources(template.Spec)
+		w.Containers = newContainers(template.Spec)
+		w.Claims = claimNames(template.Spec)
+	}
+	if n, ok, _ := unstructured.NestedInt64(item.Object, "spec", "replicas"); ok {
+		w.Replicas = int32(n)
+	}
+	return w, nil
+}
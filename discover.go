@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// discoverWorkloads 列出命名空间下匹配 selector 的 Pod，沿 controller ownerReference 找到最上层的工作负载，
+// 按 (kind, name) 去重。ReplicaSet 和 Job 会继续查找其所属的 Deployment、CronJob 或 CRD（例如 Argo Rollouts 的 Rollout），
+// 其他类型的 owner 视为最上层。没有 owner 的 Pod 被忽略。工作负载的 request、limit 和容器取自第一个 Pod，
+// 副本数为当前属于它的 Pod 数量
+func discoverWorkloads(ctx context.Context, clientset kubernetes.Interface, namespace, selector string) ([]workload, error) {
+	var workloads []workload
+	index := map[string]int{}
+	owners := map[string]*metav1.OwnerReference{}
+
+	opts := metav1.ListOptions{LabelSelector: selector, Limit: pageSize}
+	for {
+		pods, err := clientset.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("list pods in %s: %v", namespace, err)
+		}
+		for _, pod := range pods.Items {
+			owner := metav1.GetControllerOf(&pod)
+			if owner == nil {
+				continue
+			}
+			owner, err = topLevelOwner(ctx, clientset, namespace, owner, owners)
+			if err != nil {
+				return nil, err
+			}
+
+			key := owner.Kind + "/" + owner.Name
+			if i, ok := index[key]; ok {
+				workloads[i].Replicas++
+				workloads[i].Claims = append(workloads[i].Claims, claimNames(pod.Spec)...)
+				continue
+			}
+			index[key] = len(workloads)
+			workloads = append(workloads, workload{
+				Kind:       owner.Kind,
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: owner.Name},
+				Resources:  newPodResources(pod.Spec),
+				Containers: newContainers(pod.Spec),
+				Replicas:   1,
+				Claims:     claimNames(pod.Spec),
+			})
+		}
+		if pods.Continue == "" {
+			return workloads, nil
+		}
+		opts.Continue = pods.Continue
+	}
+}
+
+// topLevelOwner 返回 owner 所属的最上层 controller，结果按 kind/name 缓存在 owners 中，
+// owner 已经被删除时返回 owner 本身
+func topLevelOwner(ctx context.Context, clientset kubernetes.Interface, namespace string, owner *metav1.OwnerReference, owners map[string]*metav1.OwnerReference) (*metav1.OwnerReference, error) {
+	key := owner.Kind + "/" + owner.Name
+	if top, ok := owners[key]; ok {
+		return top, nil
+	}
+
+	var parent *metav1.OwnerReference
+	var err error
+	switch {
+	case owner.Kind == "ReplicaSet" && owner.APIVersion == "apps/v1":
+		var rs metav1.Object
+		rs, err = clientset.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err == nil {
+			parent = metav1.GetControllerOfNoCopy(rs)
+		}
+	case owner.Kind == "Job" && owner.APIVersion == "batch/v1":
+		var job metav1.Object
+		job, err = clientset.BatchV1().Jobs(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err == nil {
+			parent = metav1.GetControllerOfNoCopy(job)
+		}
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("get %s %s in %s: %v", owner.Kind, owner.Name, namespace, err)
+	}
+
+	top := owner
+	if parent != nil {
+		if top, err = topLevelOwner(ctx, clientset, namespace, parent, owners); err != nil {
+			return nil, err
+		}
+	}
+	owners[key] = top
+	return top, nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression 自定义的派生指标，在客户端根据拉取到的指标统计值计算，结果作为单独一列输出。
+//
+// 表达式支持：
+//   - 数字常量，如 100、0.5
+//   - 指标名，如 K8sWorkloadCpuCoreUsed，取值为该指标在时间范围内的主统计值（-stat 中的第一个，默认为峰值）
+//   - 四则运算 + - * / 、一元负号以及括号
+//   - 函数 max(a, b, ...)、min(a, b, ...)、abs(x)
+//
+// 引用的指标没有数据或者除数为 0 时，该列输出 -empty-value。
+type Expression struct {
+	Name string `yaml:"name"`
+	Expr string `yaml:"expr"`
+
+	node exprNode
+}
+
+// Compile 解析表达式，必须在 Eval 之前调用
+func (e *Expression) Compile() error {
+	p := &exprParser{tokens: tokenize(e.Expr)}
+	node, err := p.parseExpr()
+	if err != nil {
+		return fmt.Errorf("expression %q: %v", e.Name, err)
+	}
+	if p.pos < len(p.tokens) {
+		return fmt.Errorf("expression %q: unexpected %q", e.Name, p.tokens[p.pos])
+	}
+	e.node = node
+	return nil
+}
+
+// Metrics 返回表达式引用的指标名
+func (e *Expression) Metrics() []string {
+	var names []string
+	e.node.metrics(&names)
+	return names
+}
+
+// Eval 根据各指标的统计值计算表达式，无法计算时返回 false
+func (e *Expression) Eval(values map[string]float64) (float64, bool) {
+	v, ok := e.node.eval(values)
+	if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+type exprNode interface {
+	eval(values map[string]float64) (float64, bool)
+	metrics(names *[]string)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]float64) (float64, bool) { return float64(n), true }
+func (n numberNode) metrics(*[]string)                       {}
+
+type metricNode string
+
+func (n metricNode) eval(values map[string]float64) (float64, bool) {
+	v, ok := values[string(n)]
+	return v, ok
+}
+
+func (n metricNode) metrics(names *[]string) { *names = append(*names, string(n)) }
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n binaryNode) eval(values map[string]float64) (float64, bool) {
+	l, ok := n.left.eval(values)
+	if !ok {
+		return 0, false
+	}
+	r, ok := n.right.eval(values)
+	if !ok {
+		return 0, false
+	}
+	switch n.op {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	default:
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	}
+}
+
+func (n binaryNode) metrics(names *[]string) {
+	n.left.metrics(names)
+	n.right.metrics(names)
+}
+
+type negNode struct{ x exprNode }
+
+func (n negNode) eval(values map[string]float64) (float64, bool) {
+	v, ok := n.x.eval(values)
+	return -v, ok
+}
+
+func (n negNode) metrics(names *[]string) { n.x.metrics(names) }
+
+type callNode struct {
+	fn   string
+	args []exprNode
+}
+
+func (n callNode) eval(values map[string]float64) (float64, bool) {
+	var args []float64
+	for _, a := range n.args {
+		v, ok := a.eval(values)
+		if !ok {
+			return 0, false
+		}
+		args = append(args, v)
+	}
+	switch n.fn {
+	case "abs":
+		return math.Abs(args[0]), true
+	case "max":
+		v := args[0]
+		for _, a := range args[1:] {
+			v = math.Max(v, a)
+		}
+		return v, true
+	default:
+		v := args[0]
+		for _, a := range args[1:] {
+			v = math.Min(v, a)
+		}
+		return v, true
+	}
+}
+
+func (n callNode) metrics(names *[]string) {
+	for _, a := range n.args {
+		a.metrics(names)
+	}
+}
+
+func tokenize(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("+-*/(),", c):
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && !strings.ContainsRune("+-*/(),", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// exprParser 递归下降解析：expr = term {("+"|"-") term}，term = unary {("*"|"/") unary}
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseTerm() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "*" || p.peek() == "/" {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.peek() == "-" {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negNode{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case t == "(":
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return x, nil
+	case strings.ContainsAny(t, "+-*/),"):
+		return nil, fmt.Errorf("unexpected %q", t)
+	}
+
+	if v, err := strconv.ParseFloat(t, 64); err == nil {
+		return numberNode(v), nil
+	}
+
+	if p.peek() != "(" {
+		return metricNode(t), nil
+	}
+
+	// 函数调用
+	p.next()
+	if t != "max" && t != "min" && t != "abs" {
+		return nil, fmt.Errorf("unknown function %q", t)
+	}
+	var args []exprNode
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek() == "," {
+			p.next()
+			continue
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) after arguments of %s", t)
+		}
+		break
+	}
+	if t == "abs" && len(args) != 1 {
+		return nil, fmt.Errorf("abs takes exactly one argument")
+	}
+	return callNode{fn: t, args: args}, nil
+}
@@ -0,0 +1,138 @@
+// fake-monitor 是一个本地的云监控 DescribeStatisticData 模拟服务，用于在没有
+// 云账号的情况下端到端地运行 tke-workload-metrics。
+//
+// 在 config.yaml 中设置 endpoint: http://127.0.0.1:8080 即可让工具请求该服务。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+var (
+	addr     string
+	dataPath string
+)
+
+// fixture 按工作负载名、指标名保存返回的数据点
+type fixture map[string]map[string][]float64
+
+type condition struct {
+	Key   string
+	Value []string
+}
+
+type statisticDataRequest struct {
+	MetricNames []string
+	Conditions  []condition
+	Period      uint64
+	StartTime   string
+	EndTime     string
+}
+
+type point struct {
+	Timestamp uint64
+	Value     *float64
+}
+
+type metricDataPoint struct {
+	Dimensions []interface{}
+	Values     []point
+}
+
+type metricData struct {
+	MetricName string
+	Points     []metricDataPoint
+}
+
+func main() {
+	flag.StringVar(&addr, "addr", "127.0.0.1:8080", "listen address")
+	flag.StringVar(&dataPath, "data", "", "path to a JSON fixture of {workload: {metric: [values]}}, by default every metric returns the same canned series")
+	flag.Parse()
+
+	var data fixture
+	if dataPath != "" {
+		raw, err := ioutil.ReadFile(dataPath)
+		if err != nil {
+			klog.Fatalf("Error reading fixture: %v", err)
+		}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			klog.Fatalf("Error unmarshaling fixture: %v", err)
+		}
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, data)
+	})
+	klog.Infof("fake monitor listening on %s", addr)
+	klog.Fatal(http.ListenAndServe(addr, nil))
+}
+
+func handle(w http.ResponseWriter, r *http.Request, data fixture) {
+	if action := r.Header.Get("X-TC-Action"); action != "DescribeStatisticData" {
+		writeError(w, "InvalidAction", "unsupported action "+action)
+		return
+	}
+
+	var req statisticDataRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "InvalidParameter", err.Error())
+		return
+	}
+
+	workload := ""
+	for _, c := range req.Conditions {
+		if c.Key == "workload_name" && len(c.Value) > 0 {
+			workload = c.Value[0]
+		}
+	}
+
+	start, _ := time.Parse(time.RFC3339, req.StartTime)
+	var result []metricData
+	for _, name := range req.MetricNames {
+		values := []float64{10, 20, 30}
+		if data != nil {
+			values = data[workload][name]
+		}
+
+		md := metricData{MetricName: name}
+		if len(values) > 0 {
+			p := metricDataPoint{Dimensions: []interface{}{}}
+			for i := range values {
+				p.Values = append(p.Values, point{
+					Timestamp: uint64(start.Unix()) + uint64(i)*req.Period,
+					Value:     &values[i],
+				})
+			}
+			md.Points = []metricDataPoint{p}
+		}
+		result = append(result, md)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Response": map[string]interface{}{
+			"Period":    req.Period,
+			"StartTime": req.StartTime,
+			"EndTime":   req.EndTime,
+			"Data":      result,
+			"RequestId": "fake-request-id",
+		},
+	})
+}
+
+func writeError(w http.ResponseWriter, code, message string) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Response": map[string]interface{}{
+			"Error": map[string]string{
+				"Code":    code,
+				"Message": message,
+			},
+			"RequestId": "fake-request-id",
+		},
+	})
+}
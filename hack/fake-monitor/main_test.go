@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// deployments fake API Server 返回的 Deployment 列表
+const deployments = `{
+  "kind": "DeploymentList",
+  "apiVersion": "apps/v1",
+  "metadata": {},
+  "items": [
+    {"metadata": {"name": "api", "namespace": "default"}, "spec": {"replicas": 2, "selector": {}, "template": {"spec": {"containers": [{"name": "api", "image": "api"}]}}}},
+    {"metadata": {"name": "web", "namespace": "default"}, "spec": {"replicas": 1, "selector": {}, "template": {"spec": {"containers": [{"name": "web", "image": "web"}]}}}}
+  ]
+}`
+
+// TestReport 编译 tke-workload-metrics，对 fake API Server 和 fake monitor 运行一次，与 testdata 中的 CSV 比较。
+// 使用 -update 重新生成 golden 文件
+func TestReport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the CLI")
+	}
+	dir := t.TempDir()
+
+	data := fixture{
+		"api": {
+			"K8sWorkloadRateCpuCoreUsedRequestMax":        {10, 40, 25},
+			"K8sWorkloadRateMemWorkingSetBytesRequestMax": {50, 60, 55},
+			"K8sWorkloadRateCpuCoreUsedCluster":           {1, 2, 1.5},
+			"K8sWorkloadRateMemUsageBytesCluster":         {3, 3, 3},
+		},
+		// web 只有 CPU 数据，其余指标在 Error 列中提示为不完整响应
+		"web": {
+			"K8sWorkloadRateCpuCoreUsedRequestMax": {5, 5, 5},
+		},
+	}
+	monitor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, data)
+	}))
+	defer monitor.Close()
+
+	kube := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/apis/apps/v1/namespaces/default/deployments" {
+			w.Write([]byte(deployments))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": http.StatusNotFound})
+	}))
+	defer kube.Close()
+
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	writeFile(t, kubeconfig, `apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: `+kube.URL+`
+contexts:
+- name: fake
+  context:
+    cluster: fake
+    user: fake
+current-context: fake
+users:
+- name: fake
+  user:
+    token: fake
+`)
+	configPath := filepath.Join(dir, "config.yaml")
+	writeFile(t, configPath, `region: ap-guangzhou
+clusterID: cls-fake
+namespace: default
+secretID: fake
+secretKey: fake
+endpoint: `+monitor.URL+`
+`)
+
+	binary := filepath.Join(dir, "tke-workload-metrics")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = filepath.Join("..", "..")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build: %v\n%s", err, out)
+	}
+
+	report := filepath.Join(dir, "report.csv")
+	cmd := exec.Command(binary,
+		"-config", configPath,
+		"-kubeconfig", kubeconfig,
+		"-start", "2024-07-18T00:00:00Z",
+		"-end", "2024-07-18T03:00:00Z",
+		"-output", report,
+		"-quiet",
+	)
+	cmd.Env = withoutProxy(os.Environ())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+
+	// -dry-run 不调用云监控，在标准错误中按 -qps 估算耗时
+	dryRun := exec.Command(binary,
+		"-config", configPath,
+		"-kubeconfig", kubeconfig,
+		"-start", "2024-07-18T00:00:00Z",
+		"-end", "2024-07-18T03:00:00Z",
+		"-dry-run",
+		"-qps", "0.5",
+	)
+	dryRun.Env = withoutProxy(os.Environ())
+	var stderr bytes.Buffer
+	dryRun.Stderr = &stderr
+	plan, err := dryRun.Output()
+	if err != nil {
+		t.Fatalf("dry run: %v\n%s", err, stderr.String())
+	}
+	if n := strings.Count(string(plan), "\n"); n != 2 {
+		t.Errorf("dry run printed %d requests, want 2:\n%s", n, plan)
+	}
+	if want := "2 DescribeStatisticData calls planned, about 4s at -qps 0.5"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("dry run estimate missing %q:\n%s", want, stderr.String())
+	}
+
+	raw, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := normalizeCollectedAt(t, raw)
+	golden := filepath.Join("testdata", "report.csv")
+	if *update {
+		writeFile(t, golden, got)
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("report differs from %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
+
+// normalizeCollectedAt 把每次运行都不同的 Collected At 列替换为固定的值
+func normalizeCollectedAt(t *testing.T, report []byte) string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(report)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse report: %v", err)
+	}
+	for i, h := range records[0] {
+		if h != "Collected At" {
+			continue
+		}
+		for _, row := range records[1:] {
+			if _, err := time.Parse(time.RFC3339, row[i]); err != nil {
+				t.Errorf("Collected At %q is not RFC3339: %v", row[i], err)
+			}
+			row[i] = "<collected-at>"
+		}
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.WriteAll(records)
+	return buf.String()
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// withoutProxy 去掉代理相关的环境变量，使 CLI 直接请求本地的 fake 服务
+func withoutProxy(env []string) []string {
+	var kept []string
+	for _, e := range env {
+		name := strings.ToUpper(strings.SplitN(e, "=", 2)[0])
+		if name == "HTTP_PROXY" || name == "HTTPS_PROXY" || name == "ALL_PROXY" || name == "NO_PROXY" {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type htmlReportWriter struct{}
+
+func (htmlReportWriter) Extension() string { return "html" }
+
+// htmlCell 表格中的一个单元格，Bar 为使用率列的条形长度（0-100），其余列为负数
+type htmlCell struct {
+	Text string
+	// Sort 排序使用的值，数值列为数字，其余为原文
+	Sort string
+	Bar  float64
+	// Level 使用率的高低：low、high 或空，对应 xlsx 中的标黄和标红
+	Level string
+}
+
+type htmlReport struct {
+	Start     string
+	End       string
+	Clusters  string
+	Workloads int
+	Coverage  string
+	Header    []string
+	Rows      [][]htmlCell
+}
+
+// Write 输出单个 HTML 文件，不依赖外部资源：使用率列以条形图展示，点击表头排序
+func (htmlReportWriter) Write(w io.Writer, r *report) error {
+	utilization := map[int]bool{}
+	for i, m := range r.Columns {
+		if isUtilizationColumn(m.Name) {
+			utilization[r.columnIndex(i)-1] = true
+		}
+	}
+
+	data := htmlReport{
+		Start:     r.StartTime.Format("2006-01-02 15:04:05 MST"),
+		End:       r.EndTime.Format("2006-01-02 15:04:05 MST"),
+		Clusters:  strings.Join(reportClusters(), ", "),
+		Workloads: len(workloadRows(r.Results)),
+		Header:    r.header(),
+	}
+	covered, ratio := r.coverage()
+	data.Coverage = covered + " (" + ratio + ")"
+	for _, result := range r.Results {
+		var cells []htmlCell
+		for i, v := range r.row(result) {
+			cell := htmlCell{Text: v, Sort: v, Bar: -1}
+			if n, err := parseNumber(v); err == nil {
+				cell.Sort = strconv.FormatFloat(n, 'f', -1, 64)
+				if utilization[i] {
+					cell.Bar = math.Max(0, math.Min(n, 100))
+					if n < overProvisionedBelow {
+						cell.Level = "low"
+					} else if n >= underProvisionedAbove {
+						cell.Level = "high"
+					}
+				}
+			}
+			cells = append(cells, cell)
+		}
+		data.Rows = append(data.Rows, cells)
+	}
+	return htmlTemplate.Execute(w, data)
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>TKE workload metrics {{.Start}} ~ {{.End}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 13px; margin: 24px; color: #24292f; }
+dl { display: grid; grid-template-columns: max-content auto; gap: 4px 16px; }
+dt { font-weight: 600; }
+dd { margin: 0; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; white-space: nowrap; }
+th { background: #f6f8fa; cursor: pointer; position: sticky; top: 0; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+.bar { position: relative; min-width: 120px; }
+.bar span { position: absolute; left: 0; top: 2px; bottom: 2px; background: #54aeff; opacity: .35; }
+.bar.low span { background: #d4a72c; }
+.bar.high span { background: #cf222e; }
+.bar em { position: relative; font-style: normal; }
+</style>
+</head>
+<body>
+<dl>
+<dt>Time range</dt><dd>{{.Start}} ~ {{.End}}</dd>
+<dt>Cluster</dt><dd>{{.Clusters}}</dd>
+<dt>Workloads</dt><dd>{{.Workloads}}</dd>
+<dt>Coverage</dt><dd>{{.Coverage}}</dd>
+</dl>
+<table id="report">
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .}}{{if ge .Bar 0.0}}<td class="bar {{.Level}}" data-sort="{{.Sort}}"><span style="width: {{printf "%.1f" .Bar}}%"></span><em>{{.Text}}</em></td>{{else}}<td data-sort="{{.Sort}}">{{.Text}}</td>{{end}}{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+document.querySelectorAll("#report th").forEach(function (th, col) {
+  th.addEventListener("click", function () {
+    var desc = !th.classList.contains("desc");
+    document.querySelectorAll("#report th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+    th.classList.add(desc ? "desc" : "asc");
+    var tbody = document.querySelector("#report tbody");
+    var rows = Array.prototype.slice.call(tbody.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[col].dataset.sort, y = b.cells[col].dataset.sort;
+      var nx = parseFloat(x), ny = parseFloat(y);
+      var c = !isNaN(nx) && !isNaN(ny) ? nx - ny : isNaN(nx) !== isNaN(ny) ? (isNaN(nx) ? 1 : -1) * (desc ? -1 : 1) : x.localeCompare(y);
+      return desc ? -c : c;
+    });
+    rows.forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr/funcr"
+	"k8s.io/klog/v2"
+)
+
+// klogFlags klog 自身的命令行参数，只通过 -v 暴露日志级别
+var klogFlags = flag.NewFlagSet("klog", flag.ExitOnError)
+
+var logFormat string
+
+// 日志级别：-v=2 打印采集进度，-v=3 打印每个工作负载的开始，-v=4 打印每个指标的数据点数量，-v=5 打印原始响应
+const debugVerbosity = "5"
+
+// registerLogFlags 注册 -v 和 -log-format，必须在 flag.Parse 之前调用
+func registerLogFlags() {
+	klog.InitFlags(klogFlags)
+	flag.Var(klogFlags.Lookup("v").Value, "v", "log verbosity, 2 logs collection progress, 3 each workload, 4 data point counts, 5 raw monitor responses.")
+	flag.StringVar(&logFormat, "log-format", "text", "log format, text or json.")
+}
+
+// setupLogging 根据 -log-format 和 -debug 配置 klog，必须在 flag.Parse 之后调用
+func setupLogging() error {
+	if debug {
+		// -debug 等价于 -v=5
+		if err := klogFlags.Set("v", debugVerbosity); err != nil {
+			return err
+		}
+	}
+	switch logFormat {
+	case "text":
+	case "json":
+		// 级别由 klog 的 -v 判断，这里不再过滤
+		klog.SetLogger(funcr.NewJSON(func(obj string) {
+			fmt.Fprintln(os.Stderr, obj)
+		}, funcr.Options{LogTimestamp: true, Verbosity: 10}))
+	default:
+		return fmt.Errorf("unsupported log format %q, must be text or json", logFormat)
+	}
+	return nil
+}
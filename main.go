@@ -2,218 +2,1612 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
-	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/coderwangke/tke-workload-metrics/collector"
+	"github.com/google/uuid"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
-	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
 	monitor "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/monitor/v20180724"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
 var (
-	kubeconfig   string
-	configPath   string
-	startTimeStr string
-	endTimeStr   string
-	debug        bool
+	kubeconfig            string
+	configPath            string
+	startTimeStr          string
+	endTimeStr            string
+	debug                 bool
+	listNamespaces        bool
+	emptyValue            string
+	summaryOnly           string
+	mergePattern          string
+	mergeOutput           string
+	showConfigFlag        bool
+	labels                = labelFlags{}
+	alertThresholds       = thresholdFlags{}
+	failOnAlert           bool
+	withReportID          bool
+	withFlapping          bool
+	dedupOwned            bool
+	flapThreshold         float64
+	orderFile             string
+	minCoverage           float64
+	baselinePath          string
+	explainTarget         string
+	priorityLabel         string
+	concurrency           int
+	retryAttempts         int
+	retryBaseDelay        time.Duration
+	outputFormat          string
+	xlsxSheets            string
+	otlpEndpoint          string
+	otlpInsecure          bool
+	createdAfter          string
+	createdBefore         string
+	allNamespaces         bool
+	statFlag              string
+	splitByFlag           string
+	period                uint64
+	selector              string
+	nameFilter            string
+	excludeFilter         string
+	metricsListen         string
+	withSummary           bool
+	weightByReplicas      bool
+	pageSize              int64
+	dryRun                bool
+	qps                   float64
+	timezone              string
+	burst                 int
+	flushEvery            int
+	minUsage              float64
+	inCluster             bool
+	sortBy                string
+	output                string
+	cacheDir              string
+	cacheTTL              time.Duration
+	refresh               bool
+	sortOrder             string
+	top                   int
+	onlyIdle              bool
+	withResources         bool
+	withAbsolute          bool
+	withLimits            bool
+	withGPU               bool
+	withVolumes           bool
+	withReplicas          bool
+	granularity           string
+	precision             int
+	percentSign           bool
+	stableJSON            bool
+	thousandsSeparator    bool
+	units                 string
+	withRecommend         bool
+	withContainers        bool
+	withPods              bool
+	withCost              bool
+	discoverPods          bool
+	quiet                 bool
+	baselineStartStr      string
+	baselineEndStr        string
+	overProvisionedBelow  float64
+	underProvisionedAbove float64
+	safetyMargin          float64
+	skipNamespaces        string
+	workloadKinds         string
 )
 
-type Config struct {
-	Region    string `yaml:"region"`
-	ClusterID string `yaml:"clusterID"`
-	Namespace string `yaml:"namespace"`
-	SecretID  string `yaml:"secretID"`
-	SecretKey string `yaml:"secretKey"`
+var config Config
+
+// location -timezone 对应的时区，用于解析不带时区偏移的时间
+var location = time.Local
+
+// filenameTimeLayout 报告文件名中的时间格式，统一转换为 UTC，避免不同时区的运行产生相同或有歧义的文件名
+const filenameTimeLayout = "20060102T150405Z"
+
+// parseTime 解析 RFC3339 格式的时间，或者 -timezone 时区下 YYYY-MM-DD HH:MM:SS 格式的时间
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, location)
+	if err != nil {
+		return t, fmt.Errorf("%q is neither RFC3339 nor YYYY-MM-DD HH:MM:SS, e.g. 2024-07-18T00:00:00+08:00 or \"2024-07-18 00:00:00\"", s)
+	}
+	return t, nil
 }
 
-var config Config
+// limiter 所有集群共享的云监控调用限速，未指定 -qps 时为 nil
+var limiter *rate.Limiter
 
 func main() {
 	// 定义命令行参数
 	flag.StringVar(&kubeconfig, "kubeconfig", filepath.Join(os.Getenv("HOME"), ".kube", "config"), "path to the kubeconfig file")
-	flag.StringVar(&configPath, "config", filepath.Join(os.Getenv("HOME"), ".metrics", "config.yaml"), "path to the config file")
-	flag.StringVar(&startTimeStr, "start", "2024-07-18T00:00:00+08:00", "start time for monitoring in RFC3339 format")
-	flag.StringVar(&endTimeStr, "end", "2024-07-18T13:00:00+08:00", "end time for monitoring in RFC3339 format")
-	flag.BoolVar(&debug, "debug", false, "show raw metrics, enabled debug logging.")
+	flag.StringVar(&configPath, "config", filepath.Join(os.Getenv("HOME"), ".metrics", "config.yaml"), "path to the config file, or a directory such as a mounted ConfigMap containing config.yaml")
+	flag.BoolVar(&inCluster, "in-cluster", false, "use the in-cluster service account instead of -kubeconfig, the default when -kubeconfig does not exist inside a pod.")
+	flag.StringVar(&startTimeStr, "start", "2024-07-18T00:00:00+08:00", "start time for monitoring in RFC3339 format, or YYYY-MM-DD HH:MM:SS in -timezone")
+	flag.StringVar(&endTimeStr, "end", "2024-07-18T13:00:00+08:00", "end time for monitoring in RFC3339 format, or YYYY-MM-DD HH:MM:SS in -timezone")
+	flag.StringVar(&timezone, "timezone", "Local", "IANA time zone, e.g. Asia/Shanghai, used for times given without an offset.")
+	flag.BoolVar(&debug, "debug", false, "show raw metrics, enabled debug logging. Same as -v=5.")
+	flag.StringVar(&emptyValue, "empty-value", "N/A", "value written to cells of metrics that returned no data points.")
+	flag.StringVar(&summaryOnly, "summary-only", "", "print only the max of the first -stat of the given metric across all workloads to stdout, without writing the CSV.")
+	flag.StringVar(&mergePattern, "merge", "", "merge prior reports matching the glob into one long-format CSV, then exit without calling any API.")
+	flag.StringVar(&mergeOutput, "merge-output", "deployments_metrics_merged.csv", "output file of -merge.")
+	flag.BoolVar(&showConfigFlag, "show-config", false, "print the effective config with the source of each field to stderr, secrets redacted.")
+	flag.Var(labels, "label", "constant key=value column added to every row, can be repeated and overrides labels in the config file.")
+	flag.Var(alertThresholds, "alert-threshold", "column=value alert threshold, e.g. K8sWorkloadRateMemWorkingSetBytesRequestMax=90, can be repeated and overrides alertThresholds in the config file.")
+	flag.BoolVar(&failOnAlert, "fail-on-alert", false, "exit non-zero when any workload exceeds an alert threshold, the report is still written.")
+	flag.BoolVar(&withResources, "resources", false, "add columns with the CPU and memory requests and limits of a pod and its absolute usage derived from the percent-of-request metrics.")
+	flag.BoolVar(&withAbsolute, "absolute", false, "also collect the CPU cores used and memory working set bytes of each workload.")
+	flag.BoolVar(&withGPU, "gpu", false, "also collect the GPU usage and GPU memory usage of each workload, workloads without GPU resources report -empty-value.")
+	flag.BoolVar(&withReplicas, "replicas", false, "add a column with the desired replicas of each workload, and the peak replicas over the window when replicaMetric is configured.")
+	flag.BoolVar(&withVolumes, "volumes", false, "add columns with the capacity, used bytes and usage percent of the persistent volume claims mounted by each workload, read from the kubelets.")
+	flag.BoolVar(&withLimits, "limits", false, "also collect the CPU and memory usage as a percent of limit, to see the headroom before throttling or OOM.")
+	flag.StringVar(&units, "units", "human", "how memory columns are formatted, one of human (Ki/Mi/Gi), mib, bytes.")
+	flag.IntVar(&precision, "precision", 6, "number of decimal places of metric values.")
+	flag.BoolVar(&percentSign, "percent-sign", false, "append % to the values of percent metrics.")
+	flag.StringVar(&splitByFlag, "split-by", "", "also write the rows of each cluster and/or namespace to <cluster>/<namespace> files next to the full report, comma separated cluster,namespace.")
+	flag.BoolVar(&stableJSON, "stable", false, "sort json and jsonl rows and object keys and write numbers with -precision fixed decimals, so the same data gives byte-identical output.")
+	flag.BoolVar(&thousandsSeparator, "thousands-separator", false, "group the integer part of metric values with commas.")
+	flag.BoolVar(&quiet, "quiet", false, "do not show the collection progress bar or periodic progress logs.")
+	flag.BoolVar(&discoverPods, "discover-pods", false, "discover workloads from the controller owner references of pods instead of the Apps API, covering workloads managed by CRDs. Ignores workloadKinds.")
+	flag.BoolVar(&withCost, "cost", false, "add an Estimated Cost column from the per pod usage, replicas, time range and the prices in the config, implies -resources.")
+	flag.BoolVar(&withContainers, "containers", false, "also collect each container of a workload by container_name and output one extra row per container.")
+	flag.StringVar(&granularity, "granularity", "workload", "rows to output, one of workload, container (same as -containers), pod (one extra row per pod and skew columns on the workload row).")
+	flag.BoolVar(&withRecommend, "recommend", false, "flag over- and under-provisioned workloads and suggest requests from the observed usage, implies -resources.")
+	flag.Float64Var(&overProvisionedBelow, "over-provisioned-below", 30, "percent of request below which the usage of every resource marks a workload as over-provisioned.")
+	flag.Float64Var(&underProvisionedAbove, "under-provisioned-above", 90, "percent of request or limit at or above which the usage of any resource marks a workload as under-provisioned.")
+	flag.Float64Var(&safetyMargin, "safety-margin", 0.2, "fraction added on top of the observed usage for suggested requests.")
+	flag.BoolVar(&withSummary, "summary", false, "append summary rows to the CSV with the workload count, the top workload and the average of each metric column.")
+	flag.BoolVar(&weightByReplicas, "weight-by-replicas", false, "add a replica-weighted average, sum(value * replicas) / sum(replicas), to the -summary rows; off by default.")
+	flag.BoolVar(&withReportID, "report-id", false, "add a report_id column holding a UUID generated per run.")
+	flag.BoolVar(&dedupOwned, "dedup-owned", true, "skip workloads whose controller is another listed workload, such as a ReplicaSet managed by a listed Deployment or a Job created by a CronJob.")
+	flag.BoolVar(&withFlapping, "flapping", false, "add CPU Variance and Mem Variance columns with the coefficient of variation (stddev/mean) of the points, and a Flapping column marking workloads above -flap-threshold.")
+	flag.Float64Var(&flapThreshold, "flap-threshold", 0.5, "coefficient of variation above which -flapping marks a workload as flapping.")
+	flag.StringVar(&orderFile, "order-file", "", "file listing workload names (one per line) in the desired output order, unlisted workloads are appended sorted by name.")
+	flag.Float64Var(&minCoverage, "min-coverage", 0, "minimum fraction (0-1) of workloads that must return data for at least one metric, the run exits non-zero below it, the report is still written.")
+	flag.StringVar(&baselineStartStr, "baseline-start", "", "start of a baseline time range collected in the same run and compared with -start/-end, same format as -start.")
+	flag.StringVar(&baselineEndStr, "baseline-end", "", "end of the baseline time range, required with -baseline-start.")
+	flag.StringVar(&baselinePath, "baseline", "", "prior CSV report used as baseline, adds columns with each current statistic as a percentage of the baseline value.")
+	flag.StringVar(&explainTarget, "explain-stats", "", "print the raw points of the named workload and how each statistic is computed from them, then exit.")
+	flag.StringVar(&priorityLabel, "priority-label", "", "label or annotation key holding an integer priority, workloads with higher priority are collected first. Workloads without it default to 0 and keep the listing order.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint (host:port) to export traces of the run to, tracing is disabled when empty.")
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false, "use plain HTTP for -otlp-endpoint.")
+	flag.StringVar(&createdAfter, "created-after", "", "only collect workloads created at or after this time, in RFC3339 format or YYYY-MM-DD HH:MM:SS in -timezone.")
+	flag.StringVar(&createdBefore, "created-before", "", "only collect workloads created before this time, in RFC3339 format or YYYY-MM-DD HH:MM:SS in -timezone.")
+	flag.IntVar(&concurrency, "concurrency", 5, "number of workloads collected and namespaces listed concurrently.")
+	flag.IntVar(&retryAttempts, "retry-attempts", 5, "max attempts of a monitor API call on throttling and transient errors.")
+	flag.DurationVar(&retryBaseDelay, "retry-base-delay", time.Second, "delay before the first retry, doubled on each following retry.")
+	flag.StringVar(&outputFormat, "format", "csv", "output format, one of csv, json, jsonl, markdown, html, xlsx, prometheus, parquet.")
+	flag.StringVar(&outputFormat, "o", "csv", "shorthand for -format.")
+	flag.StringVar(&xlsxSheets, "xlsx-sheets", "namespace", "how -format xlsx splits workloads into sheets, one of namespace, cluster, single.")
+	flag.StringVar(&metricsListen, "metrics-listen", "", "after writing the report, expose the results in Prometheus text format on this address under /metrics and exit after the first scrape.")
+	flag.Float64Var(&qps, "qps", 0, "max DescribeStatisticData calls per second across all workers and clusters, 0 means unlimited.")
+	flag.IntVar(&burst, "burst", 1, "max burst of DescribeStatisticData calls above -qps.")
+	flag.Float64Var(&minUsage, "min-usage", 0, "omit workloads whose CPU and memory usage (percent of request) are both below this threshold, workloads without data are kept.")
+	flag.BoolVar(&onlyIdle, "only-idle", false, "invert -min-usage and output only the idle workloads below the threshold.")
+	flag.StringVar(&cacheDir, "cache-dir", "", "cache workload listings and collected metric points in this directory, re-runs within -cache-ttl skip the API calls.")
+	flag.DurationVar(&cacheTTL, "cache-ttl", time.Hour, "how long entries in -cache-dir are reused.")
+	flag.BoolVar(&refresh, "refresh", false, "ignore existing entries in -cache-dir and refresh them.")
+	flag.StringVar(&output, "output", "", "report path, - for stdout, or a directory to put the generated file name in. Defaults to the generated name in the current directory.")
+	flag.StringVar(&sortBy, "sort-by", "", "sort the report by cpu, memory or name before writing, overrides -order-file.")
+	flag.StringVar(&sortOrder, "sort-order", "desc", "asc or desc, used with -sort-by.")
+	flag.IntVar(&top, "top", 0, "only output the first N workloads after sorting, 0 outputs all.")
+	flag.IntVar(&flushEvery, "flush-every", 1, "write finished rows to <report>.partial.csv during collection and flush every N rows so a crash keeps partial results, 0 disables.")
+	flag.BoolVar(&dryRun, "dry-run", false, "list the workloads and print the DescribeStatisticData requests that would be sent to stdout, without calling the monitor API.")
+	flag.Int64Var(&pageSize, "page-size", 500, "max workloads returned per Kubernetes list request, larger namespaces are listed in pages.")
+	flag.StringVar(&selector, "selector", "", "label selector of the workloads to collect, e.g. team=payments.")
+	flag.StringVar(&selector, "l", "", "shorthand for -selector.")
+	flag.StringVar(&nameFilter, "name-filter", "", "regular expression the workload name must match to be collected.")
+	flag.StringVar(&nameFilter, "include", "", "same as -name-filter.")
+	flag.StringVar(&excludeFilter, "exclude", "", "regular expression of workload names to skip, e.g. -(canary|preview)$, applied after -include.")
+	flag.Uint64Var(&period, "period", 3600, "aggregation period of the monitor data in seconds, one of 60, 300, 3600, 86400. Finer periods catch shorter spikes but need more calls, long ranges are split into windows the monitor API accepts.")
+	flag.StringVar(&statFlag, "stat", "max", "comma separated statistics computed over the points of each metric, one of max, min, avg, p50, p95, p99. Each produces its own column, the first is used by expressions, -baseline and -summary-only.")
+	flag.StringVar(&statFlag, "stats", "max", "same as -stat.")
+	flag.BoolVar(&allNamespaces, "all-namespaces", false, "collect workloads in every namespace of the cluster except -skip-namespaces, ignoring namespace in the config.")
+	flag.StringVar(&workloadKinds, "workload-kinds", "", "comma separated workload kinds to collect, e.g. Deployment,StatefulSet,DaemonSet, overrides workloadKinds in the config file.")
+	flag.StringVar(&skipNamespaces, "skip-namespaces", "kube-system,kube-public", "comma separated namespaces or glob patterns such as kube-* skipped by -all-namespaces.")
+	flag.BoolVar(&listNamespaces, "list-namespaces", false, "print the resolved namespaces and their workload counts after the creation time, -name-filter and -exclude filters, then exit without collecting metrics.")
+	registerLogFlags()
 
 	flag.Parse()
+	if err := setupLogging(); err != nil {
+		klog.Fatalf("Invalid -log-format: %v", err)
+	}
 
-	data, err := ioutil.ReadFile(configPath)
-	if err != nil {
-		klog.Fatalf("Error reading config file: %v", err)
+	if mergePattern != "" {
+		// 报告通过 columns 改过列名或带有常量标签列时，需要读取生成报告时的配置才能识别这些非指标列
+		var renamed map[string]string
+		var labelKeys []string
+		if _, err := os.Stat(configPath); err == nil {
+			c, err := loadConfig(configPath)
+			if err != nil {
+				klog.Fatal(err)
+			}
+			renamed = renamedColumns(c.Columns)
+			labelKeys = sortedKeys(c.Labels)
+			for _, column := range c.Columns {
+				if _, ok := c.Labels[column.Name]; ok {
+					labelKeys = append(labelKeys, column.Header)
+				}
+			}
+		}
+		for k := range labels {
+			labelKeys = append(labelKeys, k)
+		}
+		if err := mergeReports(mergePattern, mergeOutput, renamed, nonMetricColumns(labelKeys)); err != nil {
+			klog.Fatalf("Error merging reports: %v", err)
+		}
+		return
 	}
 
-	err = yaml.Unmarshal(data, &config)
+	var err error
+	config, err = loadConfig(configPath)
 	if err != nil {
-		klog.Fatalf("Error unmarshaling YAML: %v", err)
+		klog.Fatal(err)
 	}
 
-	// Validate the configuration
+	if workloadKinds != "" {
+		config.WorkloadKinds = nil
+		for _, kind := range strings.Split(workloadKinds, ",") {
+			config.WorkloadKinds = append(config.WorkloadKinds, strings.TrimSpace(kind))
+		}
+		configSources["workloadKinds"] = "flag -workload-kinds"
+	}
+
+	if showConfigFlag {
+		showConfig(config)
+	}
+
+	// Validate the configuration，配置和时间参数中的问题一次全部报告
+	var problems []error
 	if err := validate(config); err != nil {
-		klog.Fatalf("Validation error: %v", err)
+		problems = append(problems, err)
+	}
+	// 时区无效时仍按本机时区解析 -start、-end，使它们的问题一起报告
+	if loc, err := time.LoadLocation(timezone); err != nil {
+		problems = append(problems, fmt.Errorf("-timezone: %v, e.g. -timezone Asia/Shanghai", err))
+	} else {
+		location = loc
+	}
+	startTime, startErr := parseTime(startTimeStr)
+	if startErr != nil {
+		problems = append(problems, fmt.Errorf("-start: %v", startErr))
+	}
+	endTime, endErr := parseTime(endTimeStr)
+	if endErr != nil {
+		problems = append(problems, fmt.Errorf("-end: %v", endErr))
+	}
+	if startErr == nil && endErr == nil {
+		if err := validateTimeRange(startTime, endTime); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	var baselineStart, baselineEnd time.Time
+	if baselineStartStr != "" || baselineEndStr != "" {
+		compareWindows = true
+		var baselineStartErr, baselineEndErr error
+		if baselineStart, baselineStartErr = parseTime(baselineStartStr); baselineStartErr != nil {
+			problems = append(problems, fmt.Errorf("-baseline-start: %v", baselineStartErr))
+		}
+		if baselineEnd, baselineEndErr = parseTime(baselineEndStr); baselineEndErr != nil {
+			problems = append(problems, fmt.Errorf("-baseline-end: %v", baselineEndErr))
+		}
+		if baselineStartErr == nil && baselineEndErr == nil {
+			if err := validateTimeRange(baselineStart, baselineEnd); err != nil {
+				problems = append(problems, fmt.Errorf("baseline: %v", err))
+			}
+		}
+		if baselinePath != "" {
+			problems = append(problems, fmt.Errorf("-baseline-start/-baseline-end cannot be used with -baseline"))
+		}
+		baseline = map[string]map[string]float64{}
+	}
+	if len(problems) > 0 {
+		klog.Fatalf("Validation error:\n%v", errors.Join(problems...))
+	}
+
+	if config.Labels == nil {
+		config.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		config.Labels[k] = v
+	}
+	if withReportID {
+		config.Labels["report_id"] = uuid.New().String()
+	}
+
+	for i := range config.Expressions {
+		if err := config.Expressions[i].Compile(); err != nil {
+			klog.Fatalf("Validation error: %v", err)
+		}
+	}
+
+	if baselinePath != "" {
+		baseline, err = loadBaseline(baselinePath)
+		if err != nil {
+			klog.Fatalf("Error reading baseline: %v", err)
+		}
 	}
 
-	// 解析时间参数
-	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	if !collector.ValidPeriod(period) {
+		klog.Fatalf("Invalid -period: %d, must be one of 60, 300, 3600, 86400", period)
+	}
+	if _, ok := reportWriters[outputFormat]; !ok {
+		klog.Fatalf("Invalid -format: %s", outputFormat)
+	}
+	if splitBy, err = parseSplitBy(splitByFlag); err != nil {
+		klog.Fatalf("Invalid -split-by: %v", err)
+	}
+	if len(splitBy) > 0 && output == "-" {
+		klog.Fatalf("Invalid -split-by: cannot be combined with -output -")
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "flap-threshold" && !withFlapping {
+			klog.Fatalf("Invalid -flap-threshold: requires -flapping")
+		}
+	})
+	if weightByReplicas && !withSummary {
+		klog.Fatalf("Invalid -weight-by-replicas: requires -summary")
+	}
+	if stableJSON && outputFormat != "json" && outputFormat != "jsonl" {
+		klog.Fatalf("Invalid -stable: only supported with -format json or jsonl")
+	}
+	if !containsKind(xlsxSheetModes, xlsxSheets) {
+		klog.Fatalf("Invalid -xlsx-sheets: %s, expected one of %v", xlsxSheets, xlsxSheetModes)
+	}
+	if stats, err = parseStats(statFlag); err != nil {
+		klog.Fatalf("Invalid -stat: %v", err)
+	}
+	if withRecommend || withCost {
+		withResources = true
+	}
+	if withAbsolute {
+		config.Metrics = withExtraMetrics(config.Metrics, absoluteMetricColumns)
+	}
+	if withLimits {
+		config.Metrics = withExtraMetrics(config.Metrics, limitMetricColumns)
+	}
+	if config.GPU {
+		withGPU = true
+	}
+	if withGPU {
+		config.Metrics = withExtraMetrics(config.Metrics, gpuMetricColumns)
+	}
+	switch granularity {
+	case "workload":
+	case "container":
+		withContainers = true
+	case "pod":
+		withPods = true
+	default:
+		klog.Fatalf("Invalid -granularity: %s, expected one of workload, container, pod", granularity)
+	}
+	if withPods && withContainers {
+		klog.Fatalf("Invalid -granularity: pod cannot be combined with -containers")
+	}
+	// QCE/TKE2 没有工作负载 Pod 数量的指标，默认只输出 spec 中的期望副本数
+	if withReplicas && config.ReplicaMetric == "" {
+		klog.Infof("-replicas outputs the desired replicas from the workload spec; %s has no workload pod count metric, set replicaMetric to a custom one for Peak Replicas", config.MonitorNamespace)
+	}
+	if precision < 0 || precision > 15 {
+		klog.Fatalf("Invalid -precision: %d, must be between 0 and 15", precision)
+	}
+	if !containsKind(unitModes, units) {
+		klog.Fatalf("Invalid -units: %s, expected one of %v", units, unitModes)
+	}
+	if qps < 0 {
+		klog.Fatalf("Invalid -qps: %f", qps)
+	}
+	if qps > 0 && burst < 1 {
+		klog.Fatalf("Invalid -burst: %d", burst)
+	}
+	if qps > 0 {
+		limiter = rate.NewLimiter(rate.Limit(qps), burst)
+	}
+	if pageSize < 1 {
+		klog.Fatalf("Invalid -page-size: %d", pageSize)
+	}
+	if retryAttempts < 1 {
+		klog.Fatalf("Invalid -retry-attempts: %d", retryAttempts)
+	}
+	if concurrency < 1 {
+		klog.Fatalf("Invalid -concurrency: %d", concurrency)
+	}
+	if minUsage < 0 {
+		klog.Fatalf("Invalid -min-usage: %v", minUsage)
+	}
+	if onlyIdle && minUsage == 0 {
+		klog.Fatalf("Invalid -only-idle: requires -min-usage")
+	}
+	if minUsage > 0 && !isMetricColumn(cpuRequestRatioMetric) && !isMetricColumn(memRequestRatioMetric) {
+		klog.Fatalf("Invalid -min-usage: neither %s nor %s is collected", cpuRequestRatioMetric, memRequestRatioMetric)
+	}
+	if err := validateSort(sortBy, sortOrder, top); err != nil {
+		klog.Fatalf("Invalid sort: %v", err)
+	}
+	if cacheDir != "" {
+		if err := os.MkdirAll(cacheDir, 0700); err != nil {
+			klog.Fatalf("Invalid -cache-dir: %v", err)
+		}
+		cache = &fileCache{dir: cacheDir, ttl: cacheTTL, refresh: refresh}
+	}
+	for _, p := range skipNamespacePatterns() {
+		if _, err := path.Match(p, ""); err != nil {
+			klog.Fatalf("Invalid -skip-namespaces pattern %q: %v", p, err)
+		}
+	}
+	if flushEvery < 0 {
+		klog.Fatalf("Invalid -flush-every: %d", flushEvery)
+	}
+
+	createdFilter, err := parseCreatedFilter(createdAfter, createdBefore)
 	if err != nil {
-		klog.Fatalf("Invalid start time: %v\n", err)
+		klog.Fatalf("Invalid creation time filter: %v", err)
+	}
+	var nameRegexp *regexp.Regexp
+	if nameFilter != "" {
+		if nameRegexp, err = regexp.Compile(nameFilter); err != nil {
+			klog.Fatalf("Invalid -name-filter: %v", err)
+		}
+	}
+	var excludeRegexp *regexp.Regexp
+	if excludeFilter != "" {
+		if excludeRegexp, err = regexp.Compile(excludeFilter); err != nil {
+			klog.Fatalf("Invalid -exclude: %v", err)
+		}
+	}
+	filters := workloadFilters{created: createdFilter, include: nameRegexp, exclude: excludeRegexp}
+
+	// Ctrl-C 或 SIGTERM 时取消进行中的请求，已经采集到的结果仍然写入报告
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if otlpEndpoint != "" {
+		if err := setupTracing(ctx, otlpEndpoint, otlpInsecure); err != nil {
+			klog.Fatalf("Error setting up tracing: %v", err)
+		}
+		defer flushTracing()
+	}
+
+	if summaryOnly != "" && !isMetricColumn(summaryOnly) {
+		klog.Fatalf("Invalid -summary-only metric: %s", summaryOnly)
+	}
+	if err := validateColumns(config.Columns); err != nil {
+		klog.Fatalf("Invalid columns:\n%v", err)
+	}
+	if config.AlertThresholds == nil {
+		config.AlertThresholds = map[string]float64{}
+	}
+	for k, v := range alertThresholds {
+		config.AlertThresholds[k] = v
+	}
+	if err := validateAlertThresholds(config.AlertThresholds); err != nil {
+		klog.Fatalf("Invalid -alert-threshold: %v", err)
+	}
+
+	if !listNamespaces && !dryRun {
+		if err := checkProxy(); err != nil {
+			klog.Fatalf("Error connecting to the monitor API: %v", err)
+		}
+	}
+
+	if config.DiscoverClusters != nil {
+		discovered, err := discoverClusters(ctx, config)
+		if err != nil {
+			klog.Fatalf("Error discovering clusters: %v", err)
+		}
+		config.Clusters = discovered
+	}
+	clusters := config.clusters()
+	if withCost {
+		if err := validatePrices(clusters); err != nil {
+			klog.Fatalf("Invalid -cost: %v", err)
+		}
+	}
+	filename := reportPath(fmt.Sprintf("deployments_metrics_%s_%s_to_%s.%s", reportNamespace(clusters, config.Namespaces), startTime.UTC().Format(filenameTimeLayout), endTime.UTC().Format(filenameTimeLayout), reportWriters[outputFormat].Extension()))
+	ctx, runSpan := tracer.Start(ctx, "collect")
+	defer runSpan.End()
+
+	// 采集过程中把完成的行写入中间文件，完整报告写入成功后删除
+	var partial *partialReport
+	if flushEvery > 0 && summaryOnly == "" && filename != "-" && !listNamespaces && !dryRun && explainTarget == "" {
+		partial, err = newPartialReport(filename+".partial.csv", newReport(startTime, endTime, nil), flushEvery)
+		if err != nil {
+			klog.Fatalf("Error creating partial report: %v", err)
+		}
+	}
+
+	// 依次采集每个集群，所有集群的结果合并到同一份报告中
+	var workloads []workload
+	var results []workloadResult
+	// searched -explain-stats 查找过的命名空间，多集群时为 集群名/命名空间，找不到工作负载时打印
+	var searched []string
+	// plannedCalls、plannedWindows -dry-run 时所有集群计划发送的请求数和最多的窗口数
+	var plannedCalls, plannedWindows int
+	// baselineResults 对比两个时间范围时基线时间范围内有数据的结果，包括已经删除的工作负载
+	var baselineResults []workloadResult
+	for _, cl := range clusters {
+		// 初始化Kubernetes客户端
+		clientset, err := newClientset(cl)
+		if err != nil {
+			klog.Fatal(err.Error())
+		}
+
+		namespaces, err := resolveNamespaces(ctx, clientset)
+		if err != nil {
+			klog.Fatalf("Error listing namespaces: %v", err)
+		}
+
+		if listNamespaces {
+			printNamespaces(ctx, cl, clientset, namespaces, filters)
+			continue
+		}
+
+		// 并发获取每个命名空间下的所有工作负载，按命名空间顺序合并
+		lists, err := listNamespaceWorkloads(ctx, cl, clientset, namespaces)
+		if err != nil {
+			klog.Fatal(err.Error())
+		}
+		var clusterWorkloads []workload
+		for _, items := range lists {
+			clusterWorkloads = append(clusterWorkloads, items...)
+		}
+
+		clusterWorkloads = filters.apply(clusterWorkloads, true)
+		if withPods {
+			if err := assignPods(ctx, clientset, clusterWorkloads); err != nil {
+				klog.Fatal(err.Error())
+			}
+		}
+
+		col, err := newCollector(cl)
+		if err != nil {
+			klog.Fatalf("Error creating monitor client: %v", err)
+		}
+
+		if dryRun {
+			calls, windows := printPlan(col, cl, clusterWorkloads, startTime, endTime)
+			plannedCalls += calls
+			plannedWindows = max(plannedWindows, windows)
+			continue
+		}
+
+		if explainTarget != "" {
+			for _, w := range clusterWorkloads {
+				if w.Name == explainTarget || w.Kind+"/"+w.Name == explainTarget {
+					result, err := col.CollectWorkload(ctx, w.target(), startTime, endTime)
+					if err != nil {
+						klog.Fatal(err)
+					}
+					explainStats(os.Stdout, workloadResult{Cluster: cl.Name, Namespace: w.Namespace, Kind: w.Kind, Name: w.Name, Points: result.Points})
+					return
+				}
+			}
+			for _, ns := range namespaces {
+				if cl.Name != "" {
+					ns = cl.Name + "/" + ns
+				}
+				searched = append(searched, ns)
+			}
+			continue
+		}
+
+		// 先采集基线时间范围，当前时间范围的结果完成时即可与之对比
+		if compareWindows {
+			klog.Infof("collecting baseline window %s to %s", baselineStart.Format(time.RFC3339), baselineEnd.Format(time.RFC3339))
+			baselineWorkloads := append([]workload(nil), clusterWorkloads...)
+			baselineWorkloads = append(baselineWorkloads, deletedWorkloads(ctx, col, namespaces, clusterWorkloads, baselineStart, baselineEnd, nameRegexp, excludeRegexp)...)
+			for _, r := range collectWorkloads(ctx, col, cl.ClusterID, baselineWorkloads, baselineStart, baselineEnd, concurrency, func(r *workloadResult) { r.Cluster = cl.Name }) {
+				if r.Err == nil && len(r.Points) > 0 {
+					baseline[resultKey(r)] = baselineValues(r)
+					baselineResults = append(baselineResults, r)
+				}
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		var volumeUsage map[string]volumeStats
+		if withVolumes {
+			if volumeUsage, err = listVolumeUsage(ctx, clientset); err != nil {
+				klog.Warningf("Error reading volume usage, volume columns are left empty: %v", err)
+			}
+		}
+
+		// 按优先级并发采集每个工作负载，结果仍按列表顺序输出
+		clusterResults := collectWorkloads(ctx, col, cl.ClusterID, clusterWorkloads, startTime, endTime, concurrency, func(r *workloadResult) {
+			r.Cluster = cl.Name
+			if withVolumes {
+				applyVolumes(r, volumeUsage)
+			}
+			finishResult(r)
+			if withCost {
+				estimateCost(r, cl.Region, endTime.Sub(startTime).Hours())
+			}
+			if partial != nil {
+				if err := partial.add(*r); err != nil {
+					klog.Warningf("Error writing partial report: %v", err)
+				}
+			}
+		})
+		workloads = append(workloads, clusterWorkloads...)
+		results = append(results, clusterResults...)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if dryRun {
+		printEstimate(plannedCalls, plannedWindows)
+	}
+	if listNamespaces || dryRun {
+		return
+	}
+	if explainTarget != "" {
+		klog.Fatalf("Workload %s not found in %s", explainTarget, strings.Join(searched, ", "))
+	}
+
+	if orderFile != "" {
+		order, err := readOrderFile(orderFile)
+		if err != nil {
+			klog.Fatalf("Error reading order file: %v", err)
+		}
+		results = orderResults(results, order)
+	}
+
+	covered, coverage := collectionCoverage(workloadRows(results))
+	scanned := len(workloadRows(results))
+	klog.Infof("collection coverage %.1f%% (%d/%d workloads with data)", coverage*100, covered, scanned)
+
+	// 覆盖率按过滤前的结果计算，空闲的工作负载同样算作有数据
+	if minUsage > 0 {
+		var filtered int
+		results, filtered = filterByUsage(results)
+		klog.Infof("skipped %d workloads by -min-usage %v", filtered, minUsage)
+	}
+
+	// 基线时间范围内存在、当前已经删除的工作负载不计入覆盖率，也不受 -min-usage 过滤
+	if compareWindows && ctx.Err() == nil {
+		results = append(results, removedResults(baselineResults, results)...)
+	}
+
+	// -top 只影响输出的行，失败的工作负载仍然计入退出码
+	rows := results
+	if sortBy != "" || top > 0 {
+		rows = sortResults(rows, sortBy, sortOrder, top)
+	}
+
+	if summaryOnly != "" {
+		fmt.Println(summaryValue(workloadRows(rows), summaryOnly))
+	} else {
+		// 创建CSV文件
+		rep := newReport(startTime, endTime, rows)
+		rep.Covered, rep.Scanned = covered, scanned
+		_, writeSpan := tracer.Start(ctx, "write-report", trace.WithAttributes(attribute.String("file", filename)))
+		err := writeReport(filename, rep)
+		if err == nil && len(splitBy) > 0 {
+			err = writeSplitReports(filename, rep)
+		}
+		writeSpan.End()
+		if err != nil {
+			klog.Fatal(err.Error())
+		}
+		if partial != nil {
+			if err := partial.remove(); err != nil {
+				klog.Warningf("Error removing partial report: %v", err)
+			}
+		}
+		if metricsListen != "" {
+			if err := serveMetricsOnce(metricsListen, rep); err != nil {
+				klog.Fatalf("Error serving metrics: %v", err)
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		klog.Errorf("interrupted, wrote %d of %d workloads", len(workloadRows(rows)), len(workloads))
+		runSpan.End()
+		exit(130)
+	}
+
+	// 单个工作负载失败不会中断整个报告，但最终以非 0 退出
+	failed := failedResults(results)
+	klog.Infof("%d workloads succeeded, %d failed", len(workloadRows(results))-len(failed), len(failed))
+	for _, r := range failed {
+		klog.Errorf("failed to collect %s: %v", resultKey(r), r.Err)
+	}
+
+	alerts := checkAlerts(results, config.AlertThresholds)
+	for _, a := range alerts {
+		klog.Warningf("alert: %s %s is %f, above the threshold %f", resultKey(a.Result), a.Column, a.Value, a.Threshold)
+	}
+
+	exitCode := 0
+	if len(failed) > 0 {
+		exitCode = 1
+	}
+	if failOnAlert && len(alerts) > 0 {
+		klog.Errorf("%d values exceed the alert thresholds", len(alerts))
+		exitCode = 1
+	}
+	if coverage < minCoverage {
+		klog.Errorf("collection coverage %.1f%% is below -min-coverage %.1f%%", coverage*100, minCoverage*100)
+		exitCode = 1
+	}
+	if exitCode != 0 {
+		runSpan.End()
+		exit(exitCode)
+	}
+}
+
+// printPlan 打印每个工作负载会发送的 DescribeStatisticData 请求，每行一个请求，
+// 返回请求总数和单个工作负载的时间范围最多被拆分成的窗口数
+func printPlan(col *collector.Collector, cl ClusterConfig, workloads []workload, startTime, endTime time.Time) (calls, windows int) {
+	plan := func(key string, target collector.Workload) {
+		starts := map[string]bool{}
+		for _, request := range col.Plan(target, startTime, endTime) {
+			fmt.Printf("%s\t%s\n", key, request.ToJsonString())
+			starts[*request.StartTime] = true
+			calls++
+		}
+		windows = max(windows, len(starts))
+	}
+	for _, w := range workloads {
+		key := resultKey(workloadResult{Cluster: cl.Name, Namespace: w.Namespace, Kind: w.Kind, Name: w.Name})
+		plan(key, w.target())
+		if withContainers {
+			for _, c := range w.Containers {
+				target := w.target()
+				target.Container = c.Name
+				plan(key+"/"+c.Name, target)
+			}
+		}
+		if withPods {
+			for _, pod := range w.Pods {
+				target := w.target()
+				target.Pod = pod
+				plan(key+"/"+pod, target)
+			}
+		}
+	}
+	return calls, windows
+}
+
+// printEstimate 在标准错误中打印 -dry-run 的请求总数和按 -qps 估算的耗时，不包括重试，标准输出只有请求
+func printEstimate(calls, windows int) {
+	if windows > 1 {
+		klog.Infof("the time range exceeds what one request covers at -period %d, each workload is queried in %d windows", period, windows)
+	}
+	if qps > 0 {
+		klog.Infof("%d DescribeStatisticData calls planned, about %s at -qps %g (retries not included)", calls, time.Duration(float64(calls)/qps*float64(time.Second)).Round(time.Second), qps)
+		return
+	}
+	klog.Infof("%d DescribeStatisticData calls planned, without -qps the duration depends on API latency and -concurrency", calls)
+}
+
+// failedResults 返回采集失败的工作负载
+func failedResults(results []workloadResult) []workloadResult {
+	var failed []workloadResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// createdFilter 按创建时间过滤工作负载，零值表示不限制
+type createdFilter struct {
+	after, before time.Time
+}
+
+func parseCreatedFilter(after, before string) (createdFilter, error) {
+	var f createdFilter
+	var err error
+	if after != "" {
+		if f.after, err = parseTime(after); err != nil {
+			return f, err
+		}
+	}
+	if before != "" {
+		if f.before, err = parseTime(before); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// apply 移除不在创建时间范围内的工作负载，同时返回被过滤的数量
+func (f createdFilter) apply(workloads []workload) ([]workload, int) {
+	var kept []workload
+	for _, w := range workloads {
+		created := w.CreationTimestamp.Time
+		// -discover-pods 发现的工作负载没有创建时间，不按创建时间过滤
+		if created.IsZero() {
+			kept = append(kept, w)
+			continue
+		}
+		if !f.after.IsZero() && created.Before(f.after) {
+			continue
+		}
+		if !f.before.IsZero() && !created.Before(f.before) {
+			continue
+		}
+		kept = append(kept, w)
 	}
-	endTime, err := time.Parse(time.RFC3339, endTimeStr)
+	return kept, len(workloads) - len(kept)
+}
+
+// workloadFilters 请求云监控之前过滤工作负载的条件，依次为创建时间、-name-filter 和 -exclude
+type workloadFilters struct {
+	created createdFilter
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// apply 返回通过所有条件的工作负载，verbose 时打印每个条件跳过的数量
+func (f workloadFilters) apply(workloads []workload, verbose bool) []workload {
+	workloads, filtered := f.created.apply(workloads)
+	if verbose && filtered > 0 {
+		klog.Infof("skipped %d workloads outside the creation time window", filtered)
+	}
+	if f.include != nil {
+		workloads, filtered = filterByName(workloads, f.include)
+		if verbose {
+			klog.Infof("skipped %d workloads not matching -name-filter %s", filtered, nameFilter)
+		}
+	}
+	if f.exclude != nil {
+		workloads, filtered = excludeByName(workloads, f.exclude)
+		if verbose {
+			klog.Infof("skipped %d workloads matching -exclude %s", filtered, excludeFilter)
+		}
+	}
+	return workloads
+}
+
+// filterByName 只保留名称匹配 re 的工作负载，同时返回被过滤的数量
+func filterByName(workloads []workload, re *regexp.Regexp) ([]workload, int) {
+	var kept []workload
+	for _, w := range workloads {
+		if re.MatchString(w.Name) {
+			kept = append(kept, w)
+		}
+	}
+	return kept, len(workloads) - len(kept)
+}
+
+// isIdle 判断工作负载的 CPU、内存用量占 request 的百分比（取 -stat 中第一个统计值）是否都低于 -min-usage，
+// 两者都没有数据时不是空闲，以便和没有数据的情况区分
+func isIdle(r workloadResult) bool {
+	known := false
+	for _, metric := range []string{cpuRequestRatioMetric, memRequestRatioMetric} {
+		v, ok := r.Values[metric]
+		if !ok {
+			continue
+		}
+		if v >= minUsage {
+			return false
+		}
+		known = true
+	}
+	return known
+}
+
+// filterByUsage 去掉空闲的工作负载，-only-idle 时只保留空闲的工作负载，同时返回被过滤的工作负载数量。
+// 采集失败的工作负载总是保留，容器行和 Pod 行跟随所属的工作负载行
+func filterByUsage(results []workloadResult) ([]workloadResult, int) {
+	var kept []workloadResult
+	keep, filtered := true, 0
+	for _, r := range results {
+		if r.isWorkloadRow() {
+			keep = r.Err != nil || isIdle(r) == onlyIdle
+			if !keep {
+				filtered++
+			}
+		}
+		if keep {
+			kept = append(kept, r)
+		}
+	}
+	return kept, filtered
+}
+
+// excludeByName 去掉名称匹配 re 的工作负载，同时返回被过滤的数量
+func excludeByName(workloads []workload, re *regexp.Regexp) ([]workload, int) {
+	var kept []workload
+	for _, w := range workloads {
+		if !re.MatchString(w.Name) {
+			kept = append(kept, w)
+		}
+	}
+	return kept, len(workloads) - len(kept)
+}
+
+// collectionOrder 返回按 -priority-label 从高到低排列的下标，优先级相同时保持列表顺序
+func collectionOrder(workloads []workload) []int {
+	order := make([]int, len(workloads))
+	priorities := make([]int, len(workloads))
+	for i, w := range workloads {
+		order[i] = i
+		priorities[i] = workloadPriority(w.ObjectMeta)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return priorities[order[a]] > priorities[order[b]]
+	})
+	return order
+}
+
+// workloadPriority 依次从 label 和 annotation 读取优先级，未设置或无法解析时为 0
+func workloadPriority(meta metav1.ObjectMeta) int {
+	if priorityLabel == "" {
+		return 0
+	}
+	value, ok := meta.Labels[priorityLabel]
+	if !ok {
+		value, ok = meta.Annotations[priorityLabel]
+	}
+	if !ok {
+		return 0
+	}
+	p, err := strconv.Atoi(value)
 	if err != nil {
-		klog.Fatalf("Invalid end time: %v\n", err)
+		klog.Warningf("invalid priority %q on %s/%s, using 0", value, meta.Namespace, meta.Name)
+		return 0
+	}
+	return p
+}
+
+// finishResult 根据采集到的数据点计算变异系数、request 和 limit、资源建议、基线对比以及自定义表达式列
+func finishResult(r *workloadResult) {
+	if withGPU {
+		clearGPUValues(r)
+	}
+	if withReplicas {
+		applyReplicas(r)
+	}
+	for _, vc := range activeVarianceColumns() {
+		if cv, ok := coefficientOfVariation(r.Points[vc.Metric]); ok {
+			r.Values[vc.Name] = cv
+			if cv > flapThreshold {
+				r.Flapping = true
+			}
+		}
+	}
+	if withResources {
+		applyResources(r)
+	}
+	if withRecommend {
+		recommend(r)
+	}
+	if baseline != nil {
+		applyBaseline(r)
+	}
+	for _, e := range config.Expressions {
+		if v, ok := e.Eval(r.Values); ok {
+			r.Values[e.Name] = v
+		}
+	}
+}
+
+// workloadRows 返回工作负载行，不包括 -containers 增加的容器行和 -granularity pod 增加的 Pod 行
+func workloadRows(results []workloadResult) []workloadResult {
+	var rows []workloadResult
+	for _, r := range results {
+		if r.isWorkloadRow() {
+			rows = append(rows, r)
+		}
 	}
-	// 初始化Kubernetes客户端
-	kc, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	return rows
+}
+
+// collectionCoverage 返回至少有一个指标有数据的工作负载数量及其占比，没有工作负载时视为全部覆盖
+func collectionCoverage(results []workloadResult) (int, float64) {
+	if len(results) == 0 {
+		return 0, 1
+	}
+	covered := 0
+	for _, r := range results {
+		if len(r.Points) > 0 {
+			covered++
+		}
+	}
+	return covered, float64(covered) / float64(len(results))
+}
+
+// workloadResult 单个工作负载的采集结果，Points 和 Values 中只包含有数据的指标
+type workloadResult struct {
+	// Cluster 多集群配置中的集群名，单集群时为空
+	Cluster   string
+	Namespace string
+	Kind      string
+	Name      string
+	// Container -containers 时容器行的容器名，工作负载行为空
+	Container string
+	// Pod -granularity pod 时 Pod 行的 Pod 名，工作负载行为空
+	Pod string
+	// Resources 单个 Pod 的 request 和 limit，容器行为该容器的 request 和 limit，用于 -resources
+	Resources podResources
+	// Replicas 期望的副本数，用于 -cost
+	Replicas int32
+	// Claims 工作负载行的 Pod 使用的 PVC，用于 -volumes
+	Claims []string
+	// Points 每个指标的原始数据点
+	Points map[string][]float64
+	// Values 每一列输出的值，key 为 metricColumn.Name
+	Values map[string]float64
+	// Flapping 任一指标的变异系数超过 -flap-threshold
+	Flapping bool
+	// Recommendation -recommend 的结论：over-provisioned、under-provisioned 或 ok，没有数据时为空
+	Recommendation string
+	// Comparison 对比两个时间范围时只在当前范围有数据为 added，只在基线范围有数据为 removed
+	Comparison string
+	// Partial 请求成功但没有返回有效数据点的指标，在 Error 列中提示，不算作采集失败
+	Partial []string
+	// Err 采集失败的原因，失败时 Points 为空但不代表没有数据
+	Err error
+}
+
+// isWorkloadRow 判断是否为工作负载行，而不是容器行或 Pod 行
+func (r workloadResult) isWorkloadRow() bool {
+	return r.Container == "" && r.Pod == ""
+}
+
+// reportPath 根据 -output 返回报告的路径：未设置时为当前目录下的 generated，
+// 为目录时使用目录下的 generated，- 表示标准输出，其他值原样使用
+func reportPath(generated string) string {
+	switch {
+	case output == "":
+		return generated
+	case output == "-":
+		return output
+	case strings.HasSuffix(output, string(os.PathSeparator)):
+		return filepath.Join(output, generated)
+	}
+	if info, err := os.Stat(output); err == nil && info.IsDir() {
+		return filepath.Join(output, generated)
+	}
+	return output
+}
+
+// createParentDir 创建报告所在的目录，-output 指向尚不存在的目录时使用
+func createParentDir(path string) error {
+	return os.MkdirAll(filepath.Dir(path), 0755)
+}
+
+// writeReport 按 -format 把结果写入 filename，filename 为 - 时写入标准输出。
+// 先写入临时文件再重命名，中途退出时不会留下不完整的报告
+func writeReport(filename string, rep *report) error {
+	if filename == "-" {
+		return reportWriters[outputFormat].Write(os.Stdout, rep)
+	}
+	if err := createParentDir(filename); err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	file, err := os.Create(tmp)
 	if err != nil {
-		klog.Fatal(err.Error())
+		return err
 	}
 
-	clientset, err := kubernetes.NewForConfig(kc)
+	if err := reportWriters[outputFormat].Write(file, rep); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// summaryValue 返回所有工作负载中指定指标的最大值，没有任何数据时返回 -empty-value
+func summaryValue(results []workloadResult, metricName string) string {
+	maxValue, found := float64(0), false
+	for _, r := range results {
+		if v, ok := r.Values[metricName]; ok && (!found || v > maxValue) {
+			maxValue, found = v, true
+		}
+	}
+	if !found {
+		return emptyValue
+	}
+	return fmt.Sprintf("%f", maxValue)
+}
+
+// readOrderFile 读取每行一个工作负载名的排序文件，忽略空行和 # 开头的注释
+func readOrderFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		klog.Fatal(err.Error())
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, nil
+}
+
+// orderResults 按 order 中的顺序排列结果，每行可以是工作负载名或 Kind/名称，
+// order 中没有的工作负载按名称排序追加在末尾，order 中存在但未采集到的工作负载直接忽略
+func orderResults(results []workloadResult, order []string) []workloadResult {
+	placed := make([]bool, len(results))
+	var ordered []workloadResult
+	for _, name := range order {
+		for i, r := range results {
+			if !placed[i] && (r.Name == name || r.Kind+"/"+r.Name == name) {
+				ordered = append(ordered, r)
+				placed[i] = true
+			}
+		}
+	}
+
+	var rest []workloadResult
+	for i, r := range results {
+		if !placed[i] {
+			rest = append(rest, r)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		if rest[i].Name != rest[j].Name {
+			return rest[i].Name < rest[j].Name
+		}
+		return rest[i].Kind < rest[j].Kind
+	})
+	return append(ordered, rest...)
+}
+
+// labelFlags 支持重复传入的 -label key=value 参数
+type labelFlags map[string]string
+
+func (l labelFlags) String() string {
+	var pairs []string
+	for _, k := range sortedKeys(l) {
+		pairs = append(pairs, k+"="+l[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlags) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("label must be in key=value form, got %q", value)
+	}
+	l[kv[0]] = kv[1]
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resolveNamespaces 返回本次运行需要扫描的命名空间，-all-namespaces 时从集群中列出
+func resolveNamespaces(ctx context.Context, clientset kubernetes.Interface) ([]string, error) {
+	if !allNamespaces {
+		return config.Namespaces, nil
 	}
 
-	// 获取命名空间下的所有Deployments
-	deploymentsClient := clientset.AppsV1().Deployments(config.Namespace)
-	deployments, err := deploymentsClient.List(context.TODO(), metav1.ListOptions{})
+	list, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		klog.Fatal(err.Error())
+		return nil, err
+	}
+	var namespaces []string
+	for _, ns := range list.Items {
+		if !isSkippedNamespace(ns.Name) {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	return namespaces, nil
+}
+
+// skipNamespacePatterns 返回 -skip-namespaces 中的命名空间或 glob 模式
+func skipNamespacePatterns() []string {
+	var patterns []string
+	for _, p := range strings.Split(skipNamespaces, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
 	}
+	return patterns
+}
 
-	// 创建CSV文件
-	filename := fmt.Sprintf("deployments_metrics_%s_%s_to_%s.csv", config.Namespace, startTime.Format("20060102T150405"), endTime.Format("20060102T150405"))
+// isSkippedNamespace 判断命名空间是否匹配 -skip-namespaces 中的任一名称或 glob 模式，如 kube-*
+func isSkippedNamespace(name string) bool {
+	for _, p := range skipNamespacePatterns() {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
 
-	file, err := os.Create(filename)
+// reportNamespace 返回报告文件名中的命名空间部分，扫描多个命名空间时为 multi-namespace，
+// -all-namespaces 时为 all-namespaces，多集群时加上 multi-cluster 前缀
+func reportNamespace(clusters []ClusterConfig, namespaces []string) string {
+	if len(clusters) > 1 {
+		return "multi-cluster_" + reportNamespace(nil, namespaces)
+	}
+	if allNamespaces {
+		return "all-namespaces"
+	}
+	if len(namespaces) == 1 {
+		return namespaces[0]
+	}
+	return "multi-namespace"
+}
+
+// printNamespaces 打印每个命名空间及其下的工作负载数量，多集群时每行以集群名开头。
+// 数量是经过 filters 过滤后的结果，与实际采集时的工作负载一致
+func printNamespaces(ctx context.Context, cl ClusterConfig, clients clusterClients, namespaces []string, filters workloadFilters) {
+	lists, err := listNamespaceWorkloads(ctx, cl, clients, namespaces)
 	if err != nil {
-		klog.Fatal(err.Error())
+		klog.Fatalf("Error listing workloads: %v", err)
+	}
+	for i, ns := range namespaces {
+		if cl.Name != "" {
+			fmt.Printf("%s\t", cl.Name)
+		}
+		fmt.Printf("%s\t%d\n", ns, len(filters.apply(lists[i], false)))
 	}
-	defer file.Close()
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// listNamespaceWorkloads 使用 -concurrency 个 goroutine 并发列出每个命名空间下的工作负载，结果按 namespaces 的顺序返回。
+// 有命名空间列出失败时返回其中按顺序的第一个错误，ctx 被取消时不再列出剩余的命名空间
+func listNamespaceWorkloads(ctx context.Context, cl ClusterConfig, clients clusterClients, namespaces []string) ([][]workload, error) {
+	lists := make([][]workload, len(namespaces))
+	errs := make([]error, len(namespaces))
+	jobs := make(chan int)
 
-	// 写入CSV头
-	writer.Write([]string{"Namespace", "Deployment", "CPU Usage Max (percent)", "Memory Usage Max (percent)"})
+	var wg sync.WaitGroup
+	for n := 0; n < concurrency && n < len(namespaces); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				listCtx, listSpan := tracer.Start(ctx, "list-workloads", trace.WithAttributes(attribute.String("cluster", cl.ClusterID), attribute.String("namespace", namespaces[i])))
+				lists[i], errs[i] = listWorkloadsCached(listCtx, cl, clients, namespaces[i])
+				listSpan.End()
+			}
+		}()
+	}
+	for i := range namespaces {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		}
+	}
+	close(jobs)
+	wg.Wait()
 
-	// 遍历每个Deployment
-	for _, deployment := range deployments.Items {
-		cpuPeakUsage, memPeakUsage := getDeploymentMetrics(deployment.Name, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
-		writer.Write([]string{config.Namespace, deployment.Name, fmt.Sprintf("%f", cpuPeakUsage), fmt.Sprintf("%f", memPeakUsage)})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
 	}
+	return lists, nil
 }
 
-func getDeploymentMetrics(deploymentName string, startTime, endTime string) (float64, float64) {
-	klog.Infof("start collect %s/%s metrics.", config.Namespace, deploymentName)
+// newClientset 使用集群的 kubeconfig 和 context 创建 Kubernetes client，kubeconfig 为空时使用 -kubeconfig，
+// context 为空时使用 current-context
+func newClientset(cl ClusterConfig) (clusterClients, error) {
+	path := kubeconfig
+	if cl.Kubeconfig != "" {
+		path = cl.Kubeconfig
+	}
+	kc, err := restConfig(path, cl.Context)
+	if err != nil {
+		return clusterClients{}, err
+	}
+	clientset, err := kubernetes.NewForConfig(kc)
+	if err != nil {
+		return clusterClients{}, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(kc)
+	if err != nil {
+		return clusterClients{}, err
+	}
+	return clusterClients{Interface: clientset, Dynamic: dynamicClient}, nil
+}
+
+// restConfig 返回访问集群的配置。指定 -in-cluster，或者 kubeconfig 不存在且运行在 Pod 中时，
+// 使用 Pod 的 ServiceAccount，此时不能通过 context 选择集群
+func restConfig(kubeconfig, kubecontext string) (*rest.Config, error) {
+	useInCluster := inCluster
+	if !useInCluster {
+		if _, err := os.Stat(kubeconfig); os.IsNotExist(err) && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+			klog.Infof("kubeconfig %s not found, using the in-cluster service account", kubeconfig)
+			useInCluster = true
+		}
+	}
+	if useInCluster {
+		if kubecontext != "" {
+			return nil, fmt.Errorf("context %q requires a kubeconfig, the in-cluster service account only reaches the current cluster", kubecontext)
+		}
+		return rest.InClusterConfig()
+	}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: kubecontext},
+	).ClientConfig()
+}
+
+// newMonitorClient 根据集群的凭证、地域以及配置中的 endpoint 创建云监控 client
+func newMonitorClient(cl ClusterConfig) (*monitor.Client, error) {
 	credential := common.NewCredential(
-		config.SecretID,
-		config.SecretKey,
+		cl.SecretID,
+		cl.SecretKey,
 	)
+	if cl.SessionToken != "" {
+		credential = common.NewTokenCredential(cl.SecretID, cl.SecretKey, cl.SessionToken)
+	}
 	// 实例化一个client选项，可选的，没有特殊需求可以跳过
 	cpf := profile.NewClientProfile()
-	cpf.HttpProfile.Endpoint = "monitor.tencentcloudapi.com"
+	cpf.HttpProfile.Endpoint = defaultEndpoint
+	if config.Endpoint != "" {
+		cpf.HttpProfile.Scheme, cpf.HttpProfile.Endpoint = parseEndpoint(config.Endpoint)
+	}
+	transport, err := newMonitorTransport()
+	if err != nil {
+		return nil, err
+	}
 	// 实例化要请求产品的client对象,clientProfile是可选的
-	client, _ := monitor.NewClient(credential, config.Region, cpf)
-
-	// 实例化一个请求对象,每个接口都会对应一个request对象
-	request := monitor.NewDescribeStatisticDataRequest()
-
-	request.Module = common.StringPtr("monitor")
-	request.Namespace = common.StringPtr("QCE/TKE2")
-	request.MetricNames = common.StringPtrs([]string{"K8sWorkloadRateCpuCoreUsedRequestMax", "K8sWorkloadRateMemWorkingSetBytesRequestMax"})
-	request.Conditions = []*monitor.MidQueryCondition{
-		{
-			Key:      common.StringPtr("tke_cluster_instance_id"),
-			Operator: common.StringPtr("="),
-			Value:    common.StringPtrs([]string{config.ClusterID}),
-		},
-		{
-			Key:      common.StringPtr("namespace"),
-			Operator: common.StringPtr("="),
-			Value:    common.StringPtrs([]string{config.Namespace}),
-		},
-		{
-			Key:      common.StringPtr("workload_kind"),
-			Operator: common.StringPtr("="),
-			Value:    common.StringPtrs([]string{"Deployment"}),
-		},
-		{
-			Key:      common.StringPtr("workload_name"),
-			Operator: common.StringPtr("="),
-			Value:    common.StringPtrs([]string{deploymentName}),
-		},
-	}
-
-	request.Period = common.Uint64Ptr(3600)
-	request.StartTime = common.StringPtr(startTime)
-	request.EndTime = common.StringPtr(endTime)
-
-	// 返回的resp是一个DescribeStatisticDataResponse的实例，与请求对象对应
-	response, err := client.DescribeStatisticData(request)
-	if _, ok := err.(*errors.TencentCloudSDKError); ok {
-		klog.Warningf("An API error has returned: %s", err)
-		return 0, 0
+	client, err := monitor.NewClient(credential, cl.Region, cpf)
+	if err != nil {
+		return nil, err
 	}
+	client.WithHttpTransport(transport)
+	return client, nil
+}
+
+// newCollector 根据集群配置和命令行参数创建 Collector，必须在编译表达式之后调用
+func newCollector(cl ClusterConfig) (*collector.Collector, error) {
+	client, err := newMonitorClient(cl)
 	if err != nil {
-		klog.Fatal(err)
+		return nil, err
 	}
+	return collector.New(client, collector.Config{
+		ClusterID:        cl.ClusterID,
+		MonitorNamespace: config.MonitorNamespace,
+		Metrics:          requestedMetrics(),
+		KindValues:       collectorKindValues(),
+		Period:           period,
+		RetryAttempts:    retryAttempts,
+		RetryBaseDelay:   retryBaseDelay,
+		Debug:            debug,
+		Limiter:          limiter,
+		ContainerMetrics: containerMetrics(),
+		PodMetrics:       podMetrics(),
+	}), nil
+}
 
-	if debug {
-		klog.Infof("collect %s/%s raw metrics %s.", config.Namespace, deploymentName, response.ToJsonString())
+// collectorKindValues 返回 workloadKinds 中每种类型的 workload_kind 维度值
+func collectorKindValues() map[string]string {
+	kindValues := map[string]string{}
+	for _, kind := range config.WorkloadKinds {
+		kindValues[kind] = workloadKindValue(kind)
 	}
+	// -discover-pods 发现的类型不在 workloadKinds 中，配置的维度值同样生效
+	for kind, v := range config.WorkloadKindValues {
+		kindValues[kind] = v
+	}
+	return kindValues
+}
 
-	metricRawData := response.Response.Data
+// containerMetrics 返回请求的指标中有容器维度指标的映射，配置中的 containerMetrics 优先
+func containerMetrics() map[string]string {
+	metrics := map[string]string{}
+	for _, name := range requestedMetrics() {
+		if m, ok := config.ContainerMetrics[name]; ok {
+			metrics[name] = m
+		} else if m, ok := defaultContainerMetrics[config.MonitorNamespace][name]; ok {
+			metrics[name] = m
+		}
+	}
+	return metrics
+}
 
-	result := map[string]float64{
-		"K8sWorkloadRateCpuCoreUsedRequestMax":        0,
-		"K8sWorkloadRateMemWorkingSetBytesRequestMax": 0,
+// podMetrics 返回请求的指标中有 Pod 维度指标的映射，配置中的 podMetrics 优先
+func podMetrics() map[string]string {
+	metrics := map[string]string{}
+	for _, name := range requestedMetrics() {
+		if m, ok := config.PodMetrics[name]; ok {
+			metrics[name] = m
+		} else if m, ok := defaultPodMetrics[config.MonitorNamespace][name]; ok {
+			metrics[name] = m
+		}
 	}
+	return metrics
+}
 
-	for _, metric := range metricRawData {
-		if metric.MetricName == nil || len(metric.Points) == 0 || len(metric.Points[0].Values) == 0 {
-			continue
+// validateTimeRange 检查时间范围的先后顺序，超出统计周期允许范围的部分会拆分为多次请求
+func validateTimeRange(start, end time.Time) error {
+	if !start.Before(end) {
+		return fmt.Errorf("start time %s must be before end time %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// formatValue 格式化指标值，字节数按 -units 输出，没有数据的指标输出 -empty-value
+func formatValue(result map[string]float64, metricName string) string {
+	v, ok := result[metricName]
+	if !ok {
+		return emptyValue
+	}
+	if isBytesColumn(metricName) {
+		return formatBytes(v)
+	}
+	if isReplicaColumn(metricName) {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	if percentSign && isPercentColumn(metricName) {
+		return formatNumber(v) + "%"
+	}
+	return formatNumber(v)
+}
+
+// formatNumber 按 -precision 保留小数位，-thousands-separator 时整数部分每三位加逗号
+func formatNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	if !thousandsSeparator {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i:]
+	}
+	var b strings.Builder
+	for i, c := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
 		}
+		b.WriteRune(c)
+	}
+	return sign + b.String() + fraction
+}
 
-		maxValue := float64(0)
-		for _, point := range metric.Points[0].Values {
-			if point.Value != nil {
-				if *point.Value > maxValue {
-					maxValue = *point.Value
-				}
+// parseNumber 解析 formatNumber 输出的数值，忽略千分位逗号和末尾的 %
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSuffix(s, "%"), ",", ""), 64)
+}
+
+// isPercentColumn 判断输出列是否为云监控的百分比指标
+func isPercentColumn(name string) bool {
+	metric, _, ok := statColumnMetric(name)
+	return ok && strings.Contains(metric, "Rate")
+}
+
+// requestedMetrics 返回需要向云监控请求的指标，包括自定义表达式引用的指标
+func requestedMetrics() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range config.Metrics {
+		names = append(names, m.Name)
+		seen[m.Name] = true
+	}
+	for _, e := range config.Expressions {
+		for _, name := range e.Metrics() {
+			if !seen[name] {
+				names = append(names, name)
+				seen[name] = true
 			}
 		}
-
-		result[*metric.MetricName] = maxValue
 	}
-
-	return result["K8sWorkloadRateCpuCoreUsedRequestMax"], result["K8sWorkloadRateMemWorkingSetBytesRequestMax"]
+	if withReplicas && config.ReplicaMetric != "" && !seen[config.ReplicaMetric] {
+		names = append(names, config.ReplicaMetric)
+	}
+	return names
 }
 
-func validate(config Config) error {
-	if config.Region == "" {
-		return fmt.Errorf("region is required")
+// outputColumns 返回输出的指标列，自定义表达式列排在最后
+func outputColumns() []metricColumn {
+	columns := statColumns()
+	if withResources {
+		for _, c := range resourceColumns() {
+			columns = append(columns, c.metricColumn)
+		}
 	}
-	if config.ClusterID == "" {
-		return fmt.Errorf("clusterID is required")
+	if withRecommend {
+		for _, c := range recommendColumns() {
+			columns = append(columns, c.metricColumn)
+		}
 	}
-	if config.Namespace == "" {
-		return fmt.Errorf("namespace is required")
+	if withReplicas {
+		columns = append(columns, replicaColumns()...)
 	}
-	if config.SecretID == "" {
-		return fmt.Errorf("secretID is required")
+	if withVolumes {
+		columns = append(columns, volumeColumns()...)
 	}
-	if config.SecretKey == "" {
-		return fmt.Errorf("secretKey is required")
+	if withCost {
+		columns = append(columns, costColumns()...)
 	}
-	return nil
+	if withPods {
+		columns = append(columns, skewColumns()...)
+	}
+	for _, vc := range activeVarianceColumns() {
+		columns = append(columns, metricColumn{Name: vc.Name, Header: vc.Name})
+	}
+	if baseline != nil {
+		for _, m := range statColumns() {
+			if compareWindows {
+				columns = append(columns, metricColumn{Name: baselineValueColumnName(m), Header: baselineValueColumnName(m)})
+				columns = append(columns, metricColumn{Name: deltaColumnName(m), Header: deltaColumnName(m)})
+			} else {
+				columns = append(columns, metricColumn{Name: baselineColumnName(m), Header: baselineColumnName(m)})
+			}
+		}
+	}
+	for _, e := range config.Expressions {
+		columns = append(columns, metricColumn{Name: e.Name, Header: e.Name})
+	}
+	return columns
+}
+
+func isMetricColumn(name string) bool {
+	for _, m := range config.Metrics {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// workloadKindValue 返回 Kubernetes kind 在监控 API 中对应的 workload_kind 维度值，
+// 维度值大小写不匹配时接口不会报错而是返回空数据
+func workloadKindValue(kind string) string {
+	if v, ok := config.WorkloadKindValues[kind]; ok {
+		return v
+	}
+	if v, ok := defaultWorkloadKindValues[config.MonitorNamespace][kind]; ok {
+		return v
+	}
+	return kind
+}
+
+// parseEndpoint 拆分 endpoint 中可选的 http:// 或 https:// 前缀
+func parseEndpoint(endpoint string) (scheme, host string) {
+	if strings.HasPrefix(endpoint, "http://") {
+		return "HTTP", strings.TrimPrefix(endpoint, "http://")
+	}
+	return "HTTPS", strings.TrimPrefix(endpoint, "https://")
 }
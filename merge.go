@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// windowPattern 匹配报告文件名中的时间范围，如 _20240717T160000Z_to_20240718T050000Z.csv，
+// 旧版本的报告没有 Z 后缀，使用的是 -start、-end 所在时区的时间
+var windowPattern = regexp.MustCompile(`_(\d{8}T\d{6}Z?)_to_(\d{8}T\d{6}Z?)\.csv$`)
+
+// nonMetricColumns 报告中不是指标的列：builtinColumns 的默认列名、旧版本报告的 Deployment 列和常量标签列，
+// 其余列都视为指标列。labelKeys 为配置和 -label 中的标签，-report-id 的 report_id 始终跳过
+func nonMetricColumns(labelKeys []string) map[string]bool {
+	columns := map[string]bool{"Deployment": true, "report_id": true}
+	for _, header := range builtinColumns {
+		columns[header] = true
+	}
+	for _, k := range labelKeys {
+		columns[k] = true
+	}
+	return columns
+}
+
+// renamedColumns 返回 columns 中被改名的属性列的列名到默认列名的映射，使 -merge 能识别这些报告中的属性列
+func renamedColumns(columns []metricColumn) map[string]string {
+	renamed := map[string]string{}
+	for _, c := range columns {
+		header, ok := builtinColumns[c.Name]
+		if ok && c.Header != c.Name && c.Header != header {
+			renamed[c.Header] = header
+		}
+	}
+	return renamed
+}
+
+// mergeReports 把匹配 pattern 的历史报告合并为一个长表格式的 CSV，不调用任何 API。
+// renamed 为报告中改过名的属性列，见 renamedColumns；skipped 为不输出的非指标列，见 nonMetricColumns
+func mergeReports(pattern, output string, renamed map[string]string, skipped map[string]bool) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid merge pattern %q: %v", pattern, err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no reports match %q", pattern)
+	}
+	sort.Strings(files)
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := csv.NewWriter(out)
+	writer.Write([]string{"Source", "Collected At", "Window Start", "Window End", "Cluster", "Namespace", "Kind", "Workload", "Container", "Pod", "Metric", "Value"})
+
+	for _, f := range files {
+		if filepath.Clean(f) == filepath.Clean(output) {
+			continue
+		}
+		if err := mergeReport(writer, f, renamed, skipped); err != nil {
+			return fmt.Errorf("merge %s: %v", f, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func mergeReport(writer *csv.Writer, path string, renamed map[string]string, skipped map[string]bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	// 不同版本的报告列数可能不同，摘要等附加行也允许列数不一致
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	// 旧版本的报告没有 Collected At 列，使用文件的修改时间代替，复制或归档后可能不准确
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	collectedAt := info.ModTime().Format(time.RFC3339)
+
+	windowStart, windowEnd := "", ""
+	if m := windowPattern.FindStringSubmatch(filepath.Base(path)); m != nil {
+		windowStart, windowEnd = m[1], m[2]
+	}
+
+	header := make([]string, len(records[0]))
+	column := map[string]int{}
+	for i, h := range records[0] {
+		if name, ok := renamed[h]; ok {
+			h = name
+		}
+		header[i] = h
+		column[h] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := column[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	for _, row := range records[1:] {
+		if len(row) != len(header) {
+			continue
+		}
+		workload := field(row, "Workload")
+		if workload == "" {
+			workload = field(row, "Deployment")
+		}
+		kind := field(row, "Kind")
+		if kind == "" {
+			kind = "Deployment"
+		}
+		rowCollectedAt := field(row, collectedAtColumn)
+		if rowCollectedAt == "" {
+			rowCollectedAt = collectedAt
+		}
+
+		for i, h := range header {
+			if skipped[h] {
+				continue
+			}
+			writer.Write([]string{filepath.Base(path), rowCollectedAt, windowStart, windowEnd, field(row, "Cluster"), field(row, "Namespace"), kind, workload, field(row, "Container"), field(row, "Pod"), h, row[i]})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeReportsSkipsNonMetricColumns(t *testing.T) {
+	dir := t.TempDir()
+	report := filepath.Join(dir, "deployments_metrics_default_20240718T000000Z_to_20240718T030000Z.csv")
+	writeTestFile(t, report, `Namespace,Kind,Workload,CPU Usage Max (percent),Replicas,Flapping,Recommendation,Comparison,Error,team,report_id,Collected At
+default,Deployment,api,40.000000,2,false,over-provisioned,added,,payments,3f2b,2024-07-18T03:05:00Z
+`)
+	output := filepath.Join(dir, "merged.csv")
+	if err := mergeReports(filepath.Join(dir, "*.csv"), output, nil, nonMetricColumns([]string{"team"})); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := "deployments_metrics_default_20240718T000000Z_to_20240718T030000Z.csv,2024-07-18T03:05:00Z,20240718T000000Z,20240718T030000Z,,default,Deployment,api,,,CPU Usage Max (percent),40.000000"
+	if len(lines) != 2 || lines[1] != want {
+		t.Errorf("merged report:\n%s\nwant only the metric row:\n%s", data, want)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
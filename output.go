@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// report 一次运行的完整结果，由 reportWriter 输出为不同格式
+type report struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Columns   []metricColumn
+	LabelKeys []string
+	Labels    map[string]string
+	Results   []workloadResult
+	// Summary 在表格末尾追加汇总行
+	Summary bool
+	// WeightByReplicas 汇总行增加按副本数加权的平均值
+	WeightByReplicas bool
+	// Recommend 输出 Recommendation 列
+	Recommend bool
+	// Flapping -flapping 时输出 Flapping 列
+	Flapping bool
+	// MultiCluster 配置了多个集群，表格第一列为 Cluster
+	MultiCluster bool
+	// Containers 在 Workload 之后输出 Container 列
+	Containers bool
+	// Pods 在 Workload 之后输出 Pod 列
+	Pods bool
+	// Compare 对比两个时间范围，输出 Comparison 列
+	Compare bool
+	// CollectedAt 生成报告的时间，写入 CSV 的 Collected At 列，-merge 据此区分不同批次的报告
+	CollectedAt time.Time
+	// Covered、Scanned 至少有一个指标有数据的工作负载数量和扫描的工作负载数量，按过滤前的结果计算
+	Covered, Scanned int
+}
+
+func newReport(startTime, endTime time.Time, results []workloadResult) *report {
+	covered, _ := collectionCoverage(workloadRows(results))
+	return &report{
+		StartTime:        startTime,
+		EndTime:          endTime,
+		Columns:          outputColumns(),
+		LabelKeys:        sortedKeys(config.Labels),
+		Labels:           config.Labels,
+		Results:          results,
+		Summary:          withSummary,
+		WeightByReplicas: weightByReplicas,
+		Recommend:        withRecommend,
+		Flapping:         withFlapping,
+		MultiCluster:     len(config.Clusters) > 0,
+		Containers:       withContainers,
+		Pods:             withPods,
+		Compare:          compareWindows,
+		CollectedAt:      time.Now(),
+		Covered:          covered,
+		Scanned:          len(workloadRows(results)),
+	}
+}
+
+// coverage 返回 covered/scanned 和覆盖率百分比，没有工作负载时视为全部覆盖
+func (r *report) coverage() (string, string) {
+	ratio := 1.0
+	if r.Scanned > 0 {
+		ratio = float64(r.Covered) / float64(r.Scanned)
+	}
+	return fmt.Sprintf("%d/%d", r.Covered, r.Scanned), fmt.Sprintf("%.1f%%", ratio*100)
+}
+
+// resultKey 返回 namespace/kind/name，多集群时以集群名开头，容器行和 Pod 行以容器名或 Pod 名结尾
+func resultKey(r workloadResult) string {
+	key := r.Namespace + "/" + r.Kind + "/" + r.Name
+	if r.Cluster != "" {
+		key = r.Cluster + "/" + key
+	}
+	if r.Container != "" {
+		key += "/" + r.Container
+	}
+	if r.Pod != "" {
+		key += "/" + r.Pod
+	}
+	return key
+}
+
+// summaryRows 汇总行：扫描的工作负载数量、采集覆盖率，以及每个指标列的最大值所在的工作负载和所有工作负载的平均值，不包括容器行和 Pod 行。
+// 汇总行的列数和表头不同，-merge 和 -baseline 读取报告时会跳过这些行
+func (r *report) summaryRows() [][]string {
+	results := workloadRows(r.Results)
+	covered, ratio := r.coverage()
+	rows := [][]string{
+		{"Summary"},
+		{"Workloads", strconv.Itoa(len(results))},
+		append([]string{"Coverage"}, covered, ratio),
+		{"Column", "Top Workload", "Top Value", "Average"},
+	}
+	if r.WeightByReplicas {
+		rows[3] = append(rows[3], "Replica-Weighted Average")
+	}
+	for _, m := range statColumns() {
+		var top workloadResult
+		var topValue, sum, weighted, replicas float64
+		n := 0
+		for _, result := range results {
+			v, ok := result.Values[m.Name]
+			if !ok {
+				continue
+			}
+			if n == 0 || v > topValue {
+				top, topValue = result, v
+			}
+			sum += v
+			weighted += v * float64(result.Replicas)
+			replicas += float64(result.Replicas)
+			n++
+		}
+		row := []string{m.Header, "", emptyValue, emptyValue}
+		if n > 0 {
+			row = []string{m.Header, resultKey(top), formatNumber(topValue), formatNumber(sum / float64(n))}
+		}
+		if r.WeightByReplicas {
+			row = append(row, replicaWeighted(weighted, replicas))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// replicaWeighted 按副本数加权的平均值 Σ(值 × 副本数) / Σ(副本数)，有数据的工作负载副本数都为 0 时输出 -empty-value
+func replicaWeighted(weighted, replicas float64) string {
+	if replicas == 0 {
+		return emptyValue
+	}
+	return formatNumber(weighted / replicas)
+}
+
+// header 表格类格式的表头
+func (r *report) header() []string {
+	header := []string{"Namespace", "Kind", "Workload"}
+	if r.Containers {
+		header = append(header, "Container")
+	}
+	if r.Pods {
+		header = append(header, "Pod")
+	}
+	if r.MultiCluster {
+		header = append([]string{"Cluster"}, header...)
+	}
+	for _, m := range r.Columns {
+		header = append(header, m.Header)
+	}
+	if r.Flapping {
+		header = append(header, "Flapping")
+	}
+	if r.Recommend {
+		header = append(header, "Recommendation")
+	}
+	if r.Compare {
+		header = append(header, "Comparison")
+	}
+	header = append(header, "Error")
+	return append(header, r.LabelKeys...)
+}
+
+// row 表格类格式中一个工作负载对应的行
+func (r *report) row(result workloadResult) []string {
+	row := []string{result.Namespace, result.Kind, result.Name}
+	if r.Containers {
+		row = append(row, result.Container)
+	}
+	if r.Pods {
+		row = append(row, result.Pod)
+	}
+	if r.MultiCluster {
+		row = append([]string{result.Cluster}, row...)
+	}
+	for _, m := range r.Columns {
+		row = append(row, formatValue(result.Values, m.Name))
+	}
+	if r.Flapping {
+		row = append(row, strconv.FormatBool(result.Flapping))
+	}
+	if r.Recommend {
+		row = append(row, result.Recommendation)
+	}
+	if r.Compare {
+		row = append(row, result.Comparison)
+	}
+	row = append(row, errorMessage(result))
+	for _, k := range r.LabelKeys {
+		row = append(row, r.Labels[k])
+	}
+	return row
+}
+
+// reportClusters 报告中的集群，名称和 ID 不同时输出为 name (clusterID)
+func reportClusters() []string {
+	var clusters []string
+	for _, cl := range config.clusters() {
+		if cl.Name != "" && cl.Name != cl.ClusterID {
+			clusters = append(clusters, cl.Name+" ("+cl.ClusterID+")")
+		} else {
+			clusters = append(clusters, cl.ClusterID)
+		}
+	}
+	return clusters
+}
+
+// errorMessage 返回 Error 列的内容：采集失败的原因，或者部分指标没有数据的不完整响应
+func errorMessage(r workloadResult) string {
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	if len(r.Partial) > 0 {
+		return "partial response, no valid data points for " + strings.Join(r.Partial, ", ")
+	}
+	return ""
+}
+
+// reportWriter 把 report 输出为一种文件格式，新增格式只需实现该接口并注册到 reportWriters
+type reportWriter interface {
+	// Extension 输出文件的扩展名，不包含 .
+	Extension() string
+	Write(w io.Writer, r *report) error
+}
+
+var reportWriters = map[string]reportWriter{
+	"csv":        csvReportWriter{},
+	"json":       jsonReportWriter{},
+	"jsonl":      jsonlReportWriter{},
+	"markdown":   markdownReportWriter{},
+	"html":       htmlReportWriter{},
+	"xlsx":       xlsxReportWriter{},
+	"prometheus": promReportWriter{},
+	"parquet":    parquetReportWriter{},
+}
+
+type csvReportWriter struct{}
+
+func (csvReportWriter) Extension() string { return "csv" }
+
+func (csvReportWriter) Write(w io.Writer, r *report) error {
+	writer := csv.NewWriter(w)
+	header, row := r.configuredColumns()
+	writer.Write(header)
+	for _, result := range r.Results {
+		writer.Write(row(result))
+	}
+	if r.Summary {
+		writer.Write([]string{})
+		for _, row := range r.summaryRows() {
+			writer.Write(row)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+type jsonReportWriter struct{}
+
+func (jsonReportWriter) Extension() string { return "json" }
+
+// jsonRecord JSON 输出中的一个工作负载，Metrics 的 key 为指标名或派生列名
+type jsonRecord struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Workload  string `json:"workload"`
+	// Container 只在 -containers 的容器行中输出
+	Container string `json:"container,omitempty"`
+	// Pod 只在 -granularity pod 的 Pod 行中输出
+	Pod       string                 `json:"pod,omitempty"`
+	StartTime string                 `json:"startTime"`
+	EndTime   string                 `json:"endTime"`
+	Metrics   map[string]interface{} `json:"metrics"`
+	// Units 与 Metrics 的 key 相同，单位无法确定的列（如自定义表达式）不输出
+	Units map[string]string `json:"units,omitempty"`
+	// Flapping 只在 -flapping 时输出
+	Flapping *bool `json:"flapping,omitempty"`
+	// Recommendation 只在 -recommend 时输出
+	Recommendation string `json:"recommendation,omitempty"`
+	// Comparison 只在对比两个时间范围时输出
+	Comparison string            `json:"comparison,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+func (jsonReportWriter) Write(w io.Writer, r *report) error {
+	records := []jsonRecord{}
+	for _, result := range jsonResults(r.Results) {
+		record := jsonRecord{
+			Cluster:        result.Cluster,
+			Namespace:      result.Namespace,
+			Kind:           result.Kind,
+			Workload:       result.Name,
+			Container:      result.Container,
+			Pod:            result.Pod,
+			StartTime:      r.StartTime.Format(time.RFC3339),
+			EndTime:        r.EndTime.Format(time.RFC3339),
+			Metrics:        map[string]interface{}{},
+			Units:          map[string]string{},
+			Recommendation: result.Recommendation,
+			Comparison:     result.Comparison,
+			Error:          errorMessage(result),
+			Labels:         r.Labels,
+		}
+		if r.Flapping {
+			flapping := result.Flapping
+			record.Flapping = &flapping
+		}
+		for _, m := range r.Columns {
+			record.Metrics[m.Name] = jsonValue(result.Values, m.Name)
+			if unit := columnUnit(m.Name); unit != "" {
+				record.Units[m.Name] = unit
+			}
+		}
+		records = append(records, record)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encodeJSON(encoder, records)
+}
+
+// jsonResults -stable 时按集群、命名空间、kind、名称、容器、Pod 排序，不依赖 API 返回和采集完成的顺序
+func jsonResults(results []workloadResult) []workloadResult {
+	if !stableJSON {
+		return results
+	}
+	sorted := append([]workloadResult(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		for _, f := range [][2]string{{a.Cluster, b.Cluster}, {a.Namespace, b.Namespace}, {a.Kind, b.Kind}, {a.Name, b.Name}, {a.Container, b.Container}, {a.Pod, b.Pod}} {
+			if f[0] != f[1] {
+				return f[0] < f[1]
+			}
+		}
+		return false
+	})
+	return sorted
+}
+
+// encodeJSON -stable 时先转换为通用的 map，使结构体字段与 map 的 key 一样按字母顺序输出
+func encodeJSON(encoder *json.Encoder, v interface{}) error {
+	if !stableJSON {
+		return encoder.Encode(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+	return encoder.Encode(generic)
+}
+
+// jsonValue 没有数据的指标输出 -empty-value，-empty-value=null 时输出 JSON null。-stable 时按 -precision 固定小数位数
+func jsonValue(values map[string]float64, name string) interface{} {
+	if v, ok := values[name]; ok {
+		if stableJSON {
+			return json.Number(strconv.FormatFloat(v, 'f', precision, 64))
+		}
+		return v
+	}
+	if emptyValue == "null" {
+		return nil
+	}
+	return emptyValue
+}
+
+// columnUnit 返回 JSON 输出中列的单位：percent（0-100）、cores、bytes、count、ratio（倾斜度、变异系数）
+// 或 currency（与单价相同的货币），JSON 中的值始终为原始数值，不受 -units、-percent-sign 影响。单位无法确定时返回空
+func columnUnit(name string) string {
+	for _, m := range statColumns() {
+		if name == baselineValueColumnName(m) {
+			return columnUnit(m.Name)
+		}
+		if name == baselineColumnName(m) || name == deltaColumnName(m) {
+			return "percent"
+		}
+	}
+	if metric, _, ok := statColumnMetric(name); ok {
+		switch {
+		case isPercentColumn(name):
+			return "percent"
+		case bytesMetrics[metric]:
+			return "bytes"
+		case strings.Contains(metric, "CpuCore"):
+			return "cores"
+		}
+		return ""
+	}
+	switch {
+	case isBytesColumn(name):
+		return "bytes"
+	case isReplicaColumn(name):
+		return "count"
+	case name == volumeUsageColumn:
+		return "percent"
+	case name == estimatedCostColumn:
+		return "currency"
+	case name == cpuSkewColumn || name == memSkewColumn:
+		return "ratio"
+	case name == suggestedCPUColumn || strings.HasSuffix(name, "(cores)"):
+		return "cores"
+	}
+	for _, vc := range varianceColumns {
+		if name == vc.Name {
+			return "ratio"
+		}
+	}
+	return ""
+}
+
+type jsonlReportWriter struct{}
+
+func (jsonlReportWriter) Extension() string { return "jsonl" }
+
+// jsonlRecord JSON lines 输出中的一行，对应一个工作负载的一列。Statistic 为统计方式，
+// 派生列（资源、推荐、基线等）的 Statistic 为空，Metric 为列名
+type jsonlRecord struct {
+	Cluster   string            `json:"cluster,omitempty"`
+	Namespace string            `json:"namespace"`
+	Kind      string            `json:"kind"`
+	Workload  string            `json:"workload"`
+	Container string            `json:"container,omitempty"`
+	Pod       string            `json:"pod,omitempty"`
+	Metric    string            `json:"metric"`
+	Statistic string            `json:"statistic,omitempty"`
+	Value     interface{}       `json:"value"`
+	Unit      string            `json:"unit,omitempty"`
+	StartTime string            `json:"startTime"`
+	EndTime   string            `json:"endTime"`
+	Error     string            `json:"error,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+func (jsonlReportWriter) Write(w io.Writer, r *report) error {
+	encoder := json.NewEncoder(w)
+	for _, result := range jsonResults(r.Results) {
+		for _, m := range r.Columns {
+			record := jsonlRecord{
+				Cluster:   result.Cluster,
+				Namespace: result.Namespace,
+				Kind:      result.Kind,
+				Workload:  result.Name,
+				Container: result.Container,
+				Pod:       result.Pod,
+				Metric:    m.Name,
+				Value:     jsonValue(result.Values, m.Name),
+				Unit:      columnUnit(m.Name),
+				StartTime: r.StartTime.Format(time.RFC3339),
+				EndTime:   r.EndTime.Format(time.RFC3339),
+				Error:     errorMessage(result),
+				Labels:    r.Labels,
+			}
+			if metric, stat, ok := statColumnMetric(m.Name); ok {
+				record.Metric, record.Statistic = metric, stat
+			}
+			if err := encodeJSON(encoder, record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type markdownReportWriter struct{}
+
+func (markdownReportWriter) Extension() string { return "md" }
+
+// Write 输出 GitHub 风格的 Markdown 表格，表格前是时间范围、集群、工作负载数量和采集覆盖率，便于贴到 wiki 或 PR 中
+func (markdownReportWriter) Write(w io.Writer, r *report) error {
+	fmt.Fprintf(w, "**Time range:** %s ~ %s\n\n", r.StartTime.Format("2006-01-02 15:04:05 MST"), r.EndTime.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(w, "**Cluster:** %s\n\n", strings.Join(reportClusters(), ", "))
+	fmt.Fprintf(w, "**Workloads:** %d\n\n", len(workloadRows(r.Results)))
+	covered, ratio := r.coverage()
+	fmt.Fprintf(w, "**Coverage:** %s (%s)\n\n", covered, ratio)
+
+	writeMarkdownRow(w, r.header())
+	separator := make([]string, len(r.header()))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	writeMarkdownRow(w, separator)
+	for _, result := range r.Results {
+		writeMarkdownRow(w, r.row(result))
+	}
+
+	if r.Summary {
+		rows := r.summaryRows()
+		fmt.Fprintf(w, "\n**%s**\n\n", rows[0][0])
+		// Workloads 和 Coverage 已经在表格前给出，只输出各列的统计
+		writeMarkdownRow(w, rows[3])
+		separator := make([]string, len(rows[3]))
+		for i := range separator {
+			separator[i] = "---"
+		}
+		writeMarkdownRow(w, separator)
+		for _, row := range rows[4:] {
+			writeMarkdownRow(w, row)
+		}
+	}
+	return nil
+}
+
+// writeMarkdownRow 输出表格的一行，转义单元格中的 | 和换行
+func writeMarkdownRow(w io.Writer, cells []string) {
+	escaped := make([]string, len(cells))
+	for i, c := range cells {
+		escaped[i] = strings.NewReplacer("|", "\\|", "\n", " ").Replace(c)
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+}
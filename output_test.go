@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestColumnUnit(t *testing.T) {
+	saved := config.Metrics
+	defer func() { config.Metrics = saved }()
+	config.Metrics = []metricColumn{
+		{Name: cpuRequestRatioMetric, Header: "CPU Usage Max (percent)"},
+		{Name: "K8sWorkloadCpuCoreUsed", Header: "CPU Used Max (cores)"},
+		{Name: "K8sWorkloadMemWorkingSetBytes", Header: "Memory Working Set Max"},
+	}
+	tests := map[string]string{
+		cpuRequestRatioMetric:                    "percent",
+		"K8sWorkloadCpuCoreUsed":                 "cores",
+		"K8sWorkloadMemWorkingSetBytes":          "bytes",
+		"CPU Usage Max (percent) Baseline":       "percent",
+		"CPU Used Max (cores) Baseline":          "cores",
+		"CPU Usage Max (percent) Delta (%)":      "percent",
+		"Memory Working Set Max vs Baseline (%)": "percent",
+		suggestedCPUColumn:                       "cores",
+		estimatedCostColumn:                      "currency",
+		cpuSkewColumn:                            "ratio",
+		"CPU Variance":                           "ratio",
+		"cpu_per_replica":                        "",
+	}
+	for name, want := range tests {
+		if got := columnUnit(name); got != want {
+			t.Errorf("columnUnit(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestStableJSON(t *testing.T) {
+	saved := config.Metrics
+	savedPrecision := precision
+	defer func() { config.Metrics, stableJSON, precision = saved, false, savedPrecision }()
+	config.Metrics = []metricColumn{{Name: cpuRequestRatioMetric, Header: "CPU Usage Max (percent)"}}
+	stableJSON, precision = true, 6
+
+	start := time.Date(2024, 7, 18, 0, 0, 0, 0, time.UTC)
+	api := workloadResult{Namespace: "default", Kind: "Deployment", Name: "api", Values: map[string]float64{cpuRequestRatioMetric: 1.0 / 3}}
+	web := workloadResult{Namespace: "default", Kind: "Deployment", Name: "web", Values: map[string]float64{cpuRequestRatioMetric: 25}}
+	write := func(writer reportWriter, results ...workloadResult) string {
+		var buf bytes.Buffer
+		rep := &report{StartTime: start, EndTime: start.Add(time.Hour), Columns: config.Metrics, Results: results}
+		if err := writer.Write(&buf, rep); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	for _, writer := range []reportWriter{jsonReportWriter{}, jsonlReportWriter{}} {
+		got := write(writer, web, api)
+		if want := write(writer, api, web); got != want {
+			t.Errorf("%s output depends on the row order:\n%s\n%s", writer.Extension(), got, want)
+		}
+		if !strings.Contains(got, `0.333333`) || strings.Contains(got, `0.3333333`) || !strings.Contains(got, `25.000000`) {
+			t.Errorf("%s numbers are not written with -precision decimals:\n%s", writer.Extension(), got)
+		}
+		if strings.Index(got, `"endTime"`) > strings.Index(got, `"kind"`) {
+			t.Errorf("%s keys are not sorted:\n%s", writer.Extension(), got)
+		}
+		if strings.Index(got, `"api"`) > strings.Index(got, `"web"`) {
+			t.Errorf("%s rows are not sorted:\n%s", writer.Extension(), got)
+		}
+	}
+}
+
+func TestSummaryRowsReplicaWeighted(t *testing.T) {
+	saved, savedPrecision := config.Metrics, precision
+	defer func() { config.Metrics, precision = saved, savedPrecision }()
+	config.Metrics = []metricColumn{{Name: cpuRequestRatioMetric, Header: "CPU Usage Max (percent)"}}
+	precision = 1
+
+	rep := &report{
+		WeightByReplicas: true,
+		Results: []workloadResult{
+			{Namespace: "default", Kind: "Deployment", Name: "api", Replicas: 1, Values: map[string]float64{cpuRequestRatioMetric: 90}},
+			{Namespace: "default", Kind: "Deployment", Name: "web", Replicas: 3, Values: map[string]float64{cpuRequestRatioMetric: 10}},
+			{Namespace: "default", Kind: "Deployment", Name: "idle", Replicas: 5},
+		},
+	}
+	rows := rep.summaryRows()
+	if got := rows[3][len(rows[3])-1]; got != "Replica-Weighted Average" {
+		t.Fatalf("summary header = %v", rows[3])
+	}
+	// 平均值 (90 + 10) / 2，加权平均值 (90×1 + 10×3) / (1 + 3)，没有数据的 idle 不计入
+	if got, want := rows[4], []string{"CPU Usage Max (percent)", "default/Deployment/api", "90.0", "50.0", "30.0"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("summary row = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetReportWriter struct{}
+
+func (parquetReportWriter) Extension() string { return "parquet" }
+
+// parquetRecord Parquet 输出中的一行，与 jsonlRecord 一样对应一个工作负载的一列。
+// 没有数据点的指标 Value 为 null，不受 -empty-value 影响
+type parquetRecord struct {
+	Cluster     string            `parquet:"cluster"`
+	Namespace   string            `parquet:"namespace"`
+	Kind        string            `parquet:"kind"`
+	Workload    string            `parquet:"workload"`
+	Container   string            `parquet:"container"`
+	Pod         string            `parquet:"pod"`
+	Metric      string            `parquet:"metric"`
+	Statistic   string            `parquet:"statistic"`
+	Value       *float64          `parquet:"value,optional"`
+	WindowStart int64             `parquet:"window_start,timestamp(millisecond)"`
+	WindowEnd   int64             `parquet:"window_end,timestamp(millisecond)"`
+	Error       string            `parquet:"error"`
+	Labels      map[string]string `parquet:"labels"`
+}
+
+func (parquetReportWriter) Write(w io.Writer, r *report) error {
+	writer := parquet.NewGenericWriter[parquetRecord](w)
+	for _, result := range r.Results {
+		for _, m := range r.Columns {
+			record := parquetRecord{
+				Cluster:     result.Cluster,
+				Namespace:   result.Namespace,
+				Kind:        result.Kind,
+				Workload:    result.Name,
+				Container:   result.Container,
+				Pod:         result.Pod,
+				Metric:      m.Name,
+				WindowStart: r.StartTime.UnixMilli(),
+				WindowEnd:   r.EndTime.UnixMilli(),
+				Error:       errorMessage(result),
+				Labels:      r.Labels,
+			}
+			if v, ok := result.Values[m.Name]; ok {
+				record.Value = &v
+			}
+			if metric, stat, ok := statColumnMetric(m.Name); ok {
+				record.Metric, record.Statistic = metric, stat
+			}
+			if _, err := writer.Write([]parquetRecord{record}); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Close()
+}
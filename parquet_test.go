@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetReportWriter(t *testing.T) {
+	start := time.Date(2024, 7, 18, 0, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	saved := config.Metrics
+	defer func() { config.Metrics = saved }()
+	config.Metrics = []metricColumn{{Name: cpuRequestRatioMetric}}
+	rep := &report{
+		StartTime: start,
+		EndTime:   end,
+		Columns:   []metricColumn{{Name: cpuRequestRatioMetric}, {Name: "CPU Request"}},
+		Results: []workloadResult{
+			{Cluster: "cls-a", Namespace: "default", Kind: "Deployment", Name: "api", Values: map[string]float64{cpuRequestRatioMetric: 40}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := (parquetReportWriter{}).Write(&buf, rep); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := parquet.Read[parquetRecord](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+
+	got := rows[0]
+	if got.Cluster != "cls-a" || got.Workload != "api" || got.Metric != cpuRequestRatioMetric || got.Statistic != "max" {
+		t.Errorf("unexpected identity: %+v", got)
+	}
+	if got.Value == nil || *got.Value != 40 {
+		t.Errorf("value = %v, want 40", got.Value)
+	}
+	if got.WindowStart != start.UnixMilli() || got.WindowEnd != end.UnixMilli() {
+		t.Errorf("window = %d ~ %d, want %d ~ %d", got.WindowStart, got.WindowEnd, start.UnixMilli(), end.UnixMilli())
+	}
+	// 没有数据点的列为 null，不使用 -empty-value
+	if rows[1].Metric != "CPU Request" || rows[1].Statistic != "" || rows[1].Value != nil {
+		t.Errorf("unexpected empty column: %+v", rows[1])
+	}
+}
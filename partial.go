@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+)
+
+// partialReport 采集过程中逐行写入的 CSV，进程中途退出（例如 klog.Fatal 不会执行 defer）时保留已经采集到的结果。
+// 行按采集完成的顺序写入，不包含汇总行，完整报告写入成功后删除
+type partialReport struct {
+	path   string
+	file   *os.File
+	writer *csv.Writer
+	// row 与最终 CSV 报告相同的列
+	row func(workloadResult) []string
+	// every 每写入多少行刷新一次
+	every int
+	rows  int
+}
+
+func newPartialReport(path string, rep *report, every int) (*partialReport, error) {
+	if err := createParentDir(path); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	header, row := rep.configuredColumns()
+	p := &partialReport{path: path, file: file, writer: csv.NewWriter(file), row: row, every: every}
+	p.writer.Write(header)
+	p.writer.Flush()
+	return p, p.writer.Error()
+}
+
+// add 写入一个工作负载的行，达到 -flush-every 行时刷新到文件
+func (p *partialReport) add(r workloadResult) error {
+	p.writer.Write(p.row(r))
+	p.rows++
+	if p.rows%p.every == 0 {
+		p.writer.Flush()
+	}
+	return p.writer.Error()
+}
+
+// remove 关闭并删除文件，在完整报告写入成功后调用
+func (p *partialReport) remove() error {
+	p.writer.Flush()
+	if err := p.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(p.path)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/coderwangke/tke-workload-metrics/collector"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// -granularity pod 在工作负载行增加的列，为 Pod 之间最大值与平均值的比值，1 表示负载完全均衡
+const (
+	cpuSkewColumn = "CPU Skew"
+	memSkewColumn = "Memory Skew"
+)
+
+// skewMetrics 计算倾斜度使用的指标，取 -stat 中第一个统计值
+var skewMetrics = []struct {
+	column string
+	metric string
+}{
+	{column: cpuSkewColumn, metric: cpuRequestRatioMetric},
+	{column: memSkewColumn, metric: memRequestRatioMetric},
+}
+
+func skewColumns() []metricColumn {
+	var columns []metricColumn
+	for _, s := range skewMetrics {
+		columns = append(columns, metricColumn{Name: s.column, Header: s.column})
+	}
+	return columns
+}
+
+// assignPods 列出各命名空间下的 Pod，沿 controller ownerReference 找到所属的工作负载并记录 Pod 名。
+// 只包括当前存在的 Pod，时间范围内已经被删除的 Pod 不会输出
+func assignPods(ctx context.Context, clientset kubernetes.Interface, workloads []workload) error {
+	index := map[string]int{}
+	var namespaces []string
+	seen := map[string]bool{}
+	for i, w := range workloads {
+		if !seen[w.Namespace] {
+			seen[w.Namespace] = true
+			namespaces = append(namespaces, w.Namespace)
+		}
+		index[w.Namespace+"/"+w.Kind+"/"+w.Name] = i
+	}
+
+	for _, namespace := range namespaces {
+		owners := map[string]*metav1.OwnerReference{}
+		opts := metav1.ListOptions{Limit: pageSize}
+		for {
+			pods, err := clientset.CoreV1().Pods(namespace).List(ctx, opts)
+			if err != nil {
+				return fmt.Errorf("list pods in %s: %v", namespace, err)
+			}
+			for _, pod := range pods.Items {
+				owner := metav1.GetControllerOf(&pod)
+				if owner == nil {
+					continue
+				}
+				owner, err = topLevelOwner(ctx, clientset, namespace, owner, owners)
+				if err != nil {
+					return err
+				}
+				if i, ok := index[namespace+"/"+owner.Kind+"/"+owner.Name]; ok {
+					workloads[i].Pods = append(workloads[i].Pods, pod.Name)
+				}
+			}
+			if pods.Continue == "" {
+				break
+			}
+			opts.Continue = pods.Continue
+		}
+	}
+	return nil
+}
+
+// collectPods 按 pod_name 维度采集工作负载的每个 Pod，只返回有数据的 Pod。
+// Pod 维度的指标没有数据或采集失败时只保留工作负载行
+func collectPods(ctx context.Context, col *collector.Collector, clusterID string, w workload, startTime, endTime time.Time) []workloadResult {
+	var results []workloadResult
+	for _, pod := range w.Pods {
+		target := w.target()
+		target.Pod = pod
+		result, err := collectCached(ctx, col, clusterID, target, startTime, endTime)
+		if err != nil {
+			klog.Warningf("collect %s metrics failed, keeping only the workload row: %v", target, err)
+			continue
+		}
+		if len(result.Points) == 0 {
+			continue
+		}
+		results = append(results, workloadResult{
+			Namespace: w.Namespace,
+			Kind:      w.Kind,
+			Name:      w.Name,
+			Pod:       pod,
+			Resources: w.Resources,
+			Replicas:  1,
+			Points:    result.Points,
+			Partial:   result.Partial,
+			Values:    statValues(result.Points),
+		})
+	}
+	return results
+}
+
+// applySkew 计算每个倾斜度指标在 Pod 之间最大值与平均值的比值，少于两个 Pod 有数据或平均值为 0 时不输出
+func applySkew(r *workloadResult, pods []workloadResult) {
+	for _, s := range skewMetrics {
+		var max, sum float64
+		n := 0
+		for _, p := range pods {
+			v, ok := p.Values[s.metric]
+			if !ok {
+				continue
+			}
+			if n == 0 || v > max {
+				max = v
+			}
+			sum += v
+			n++
+		}
+		if n < 2 || sum == 0 {
+			continue
+		}
+		r.Values[s.column] = max / (sum / float64(n))
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// progressInterval 标准错误不是终端时打印进度日志的间隔
+const progressInterval = 10 * time.Second
+
+// progress 显示采集进度和预计剩余时间。标准错误是终端且日志为文本格式时原地刷新进度条，否则定期打印日志。
+// 只能在汇总结果的 goroutine 中调用，因此并发采集时计数也是准确的
+type progress struct {
+	total   int
+	done    int
+	start   time.Time
+	last    time.Time
+	tty     bool
+	printed bool
+}
+
+// newProgress 创建 total 个工作负载的进度，-quiet 时返回 nil，nil 的 progress 不输出任何内容
+func newProgress(total int) *progress {
+	if quiet || total == 0 {
+		return nil
+	}
+	now := time.Now()
+	return &progress{total: total, start: now, last: now, tty: isTerminal(os.Stderr) && logFormat == "text"}
+}
+
+// isTerminal 判断 f 是否为终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// add 记录一个完成的工作负载
+func (p *progress) add() {
+	if p == nil {
+		return
+	}
+	p.done++
+	switch {
+	case p.tty:
+		p.printed = true
+		const width = 30
+		filled := width * p.done / p.total
+		fmt.Fprintf(os.Stderr, "\r[%s%s] %s", strings.Repeat("=", filled), strings.Repeat(" ", width-filled), p.status())
+	case time.Since(p.last) >= progressInterval || p.done == p.total:
+		p.last = time.Now()
+		klog.Info(p.status())
+	}
+}
+
+// status 返回 "N of M workloads collected, ~X remaining"
+func (p *progress) status() string {
+	if p.done == p.total {
+		return fmt.Sprintf("%d of %d workloads collected in %s", p.done, p.total, time.Since(p.start).Round(time.Second))
+	}
+	elapsed := time.Since(p.start)
+	remaining := time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done))
+	return fmt.Sprintf("%d of %d workloads collected, ~%s remaining", p.done, p.total, remaining.Round(time.Second))
+}
+
+// finish 结束进度条所在的行，之后的日志从新的一行开始
+func (p *progress) finish() {
+	if p != nil && p.printed {
+		fmt.Fprintln(os.Stderr)
+	}
+}
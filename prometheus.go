@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// promReportWriter 按 Prometheus 文本格式输出，每个工作负载的每一列对应一个样本，
+// 没有数据的单元格不输出样本。时间范围通过 window 开始、结束时间两个 gauge 给出
+type promReportWriter struct{}
+
+func (promReportWriter) Extension() string { return "prom" }
+
+func (promReportWriter) Write(w io.Writer, r *report) error {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "# HELP tke_workload_metric Statistic of a TKE workload monitor metric over the report window.")
+	fmt.Fprintln(&buf, "# TYPE tke_workload_metric gauge")
+	for _, result := range r.Results {
+		for _, m := range r.Columns {
+			v, ok := result.Values[m.Name]
+			if !ok {
+				continue
+			}
+			var labels [][2]string
+			if result.Cluster != "" {
+				labels = append(labels, [2]string{"cluster", result.Cluster})
+			}
+			labels = append(labels, [][2]string{
+				{"namespace", result.Namespace},
+				{"kind", result.Kind},
+				{"workload", result.Name},
+			}...)
+			if result.Container != "" {
+				labels = append(labels, [2]string{"container", result.Container})
+			}
+			if result.Pod != "" {
+				labels = append(labels, [2]string{"pod", result.Pod})
+			}
+			labels = append(labels, [][2]string{
+				{"metric", m.Name},
+				{"column", m.Header},
+			}...)
+			for _, k := range r.LabelKeys {
+				labels = append(labels, [2]string{promLabelName(k), r.Labels[k]})
+			}
+			fmt.Fprintf(&buf, "tke_workload_metric{%s} %g\n", promLabels(labels), v)
+		}
+	}
+
+	fmt.Fprintln(&buf, "# HELP tke_workload_report_window_start_seconds Start of the report window as a unix timestamp.")
+	fmt.Fprintln(&buf, "# TYPE tke_workload_report_window_start_seconds gauge")
+	fmt.Fprintf(&buf, "tke_workload_report_window_start_seconds %d\n", r.StartTime.Unix())
+	fmt.Fprintln(&buf, "# HELP tke_workload_report_window_end_seconds End of the report window as a unix timestamp.")
+	fmt.Fprintln(&buf, "# TYPE tke_workload_report_window_end_seconds gauge")
+	fmt.Fprintf(&buf, "tke_workload_report_window_end_seconds %d\n", r.EndTime.Unix())
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// reservedPromLabels 样本自带的 label，附加列的 key 与之重名时加上 label_ 前缀，避免同一样本出现重复的 label
+var reservedPromLabels = map[string]bool{
+	"cluster":   true,
+	"namespace": true,
+	"kind":      true,
+	"workload":  true,
+	"container": true,
+	"pod":       true,
+	"metric":    true,
+	"column":    true,
+}
+
+// promLabelName 把附加列的 key 转换为合法的 Prometheus label 名，例如 namespace 转换为 label_namespace
+func promLabelName(key string) string {
+	name := invalidLabelChars.ReplaceAllString(key, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	if reservedPromLabels[name] {
+		name = "label_" + name
+	}
+	return name
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabels(labels [][2]string) string {
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, l[0], labelValueEscaper.Replace(l[1]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// serveMetricsOnce 在 addr 上以 Prometheus 文本格式暴露 /metrics，第一次抓取成功后返回
+func serveMetricsOnce(addr string, r *report) error {
+	var body bytes.Buffer
+	if err := (promReportWriter{}).Write(&body, r); err != nil {
+		return err
+	}
+
+	scraped := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if _, err := w.Write(body.Bytes()); err == nil {
+			select {
+			case scraped <- struct{}{}:
+			default:
+			}
+		}
+	})
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	klog.Infof("serving metrics on %s/metrics until the first scrape", addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-scraped:
+		return server.Shutdown(context.Background())
+	}
+}
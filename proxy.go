@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultEndpoint 未配置 endpoint 时使用的云监控 API 地址
+const defaultEndpoint = "monitor.tencentcloudapi.com"
+
+// monitorURL 云监控 API 的完整地址，用于判断是否需要经过代理
+func monitorURL() *url.URL {
+	scheme, host := "HTTPS", defaultEndpoint
+	if config.Endpoint != "" {
+		scheme, host = parseEndpoint(config.Endpoint)
+	}
+	if scheme == "HTTP" {
+		return &url.URL{Scheme: "http", Host: host}
+	}
+	return &url.URL{Scheme: "https", Host: host}
+}
+
+// proxyFunc 返回云监控 client 使用的代理：优先使用配置中的 proxy，否则读取 HTTPS_PROXY、HTTP_PROXY、NO_PROXY 环境变量
+func proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if config.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(config.Proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q, expected e.g. http://proxy.example.com:3128", config.Proxy)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// newMonitorTransport 创建带代理配置的 http.Transport
+func newMonitorTransport() (*http.Transport, error) {
+	proxy, err := proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	return transport, nil
+}
+
+// checkProxy 请求云监控需要经过代理时，提前检查代理是否可以连接，避免每个请求都重试到超时
+func checkProxy() error {
+	proxy, err := proxyFunc()
+	if err != nil {
+		return err
+	}
+	u, err := proxy(&http.Request{URL: monitorURL()})
+	if err != nil {
+		return fmt.Errorf("resolve proxy: %v", err)
+	}
+	if u == nil {
+		return nil
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+		if u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+	conn, err := net.DialTimeout("tcp", host, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("proxy %s is unreachable, check proxy in the config or HTTPS_PROXY: %v", u.Redacted(), err)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,69 @@
+package main
+
+const (
+	suggestedCPUColumn    = "Suggested CPU Request"
+	suggestedMemoryColumn = "Suggested Memory Request"
+)
+
+// recommendColumns 返回 -recommend 增加的建议 request 列，值为单个 Pod 的绝对用量乘以 1 + -safety-margin
+func recommendColumns() []resourceColumn {
+	var active []resourceColumn
+	for _, c := range []resourceColumn{
+		{metricColumn: metricColumn{Name: suggestedCPUColumn, Header: suggestedCPUColumn + " (cores)"}, Metric: cpuRequestRatioMetric},
+		{metricColumn: metricColumn{Name: suggestedMemoryColumn, Header: suggestedMemoryColumn}, Bytes: true, Metric: memRequestRatioMetric},
+	} {
+		if isMetricColumn(c.Metric) {
+			active = append(active, c)
+		}
+	}
+	return active
+}
+
+// recommend 根据使用量占 request、limit 的比例判断资源是否配置过多或不足，必须在 applyResources 之后调用。
+// 任一资源的用量达到 request 或 limit 的 -under-provisioned-above 时为 under-provisioned，
+// 所有资源都低于 request 的 -over-provisioned-below 时为 over-provisioned，没有可用数据时为空
+func recommend(r *workloadResult) {
+	res := r.Resources
+	checks := []struct {
+		usage, request, limit float64
+		ok                    bool
+		column                string
+	}{
+		{column: suggestedCPUColumn, request: res.CPURequest, limit: res.CPULimit},
+		{column: suggestedMemoryColumn, request: res.MemoryRequest, limit: res.MemoryLimit},
+	}
+	checks[0].usage, checks[0].ok = r.Values["CPU Usage Max per Pod (cores)"]
+	checks[1].usage, checks[1].ok = r.Values["Memory Usage Max per Pod"]
+
+	under, over, known := false, true, false
+	for _, c := range checks {
+		if !c.ok {
+			continue
+		}
+		known = true
+		r.Values[c.column] = c.usage * (1 + safetyMargin)
+
+		ratio := c.usage / c.request * 100
+		if c.limit > 0 && c.usage/c.limit*100 > ratio {
+			ratio = c.usage / c.limit * 100
+		}
+		if ratio >= underProvisionedAbove {
+			under = true
+		}
+		if c.usage/c.request*100 >= overProvisionedBelow {
+			over = false
+		}
+	}
+
+	if !known {
+		return
+	}
+	switch {
+	case under:
+		r.Recommendation = "under-provisioned"
+	case over:
+		r.Recommendation = "over-provisioned"
+	default:
+		r.Recommendation = "ok"
+	}
+}
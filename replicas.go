@@ -0,0 +1,38 @@
+package main
+
+import "math"
+
+// -replicas 增加的列
+const (
+	replicasColumn     = "Replicas"
+	peakReplicasColumn = "Peak Replicas"
+)
+
+// replicaColumns 返回 -replicas 增加的列，配置了 replicaMetric 时才输出观测到的峰值副本数
+func replicaColumns() []metricColumn {
+	columns := []metricColumn{{Name: replicasColumn, Header: replicasColumn}}
+	if config.ReplicaMetric != "" {
+		columns = append(columns, metricColumn{Name: peakReplicasColumn, Header: peakReplicasColumn})
+	}
+	return columns
+}
+
+// isReplicaColumn 判断输出列是否为副本数，副本数按整数输出
+func isReplicaColumn(name string) bool {
+	return withReplicas && (name == replicasColumn || name == peakReplicasColumn)
+}
+
+// applyReplicas 填充工作负载行的期望副本数和时间范围内 replicaMetric 的最大值，容器行和 Pod 行不输出
+func applyReplicas(r *workloadResult) {
+	if !r.isWorkloadRow() {
+		return
+	}
+	r.Values[replicasColumn] = float64(r.Replicas)
+	if points := r.Points[config.ReplicaMetric]; config.ReplicaMetric != "" && len(points) > 0 {
+		peak := points[0]
+		for _, v := range points[1:] {
+			peak = math.Max(peak, v)
+		}
+		r.Values[peakReplicasColumn] = peak
+	}
+}
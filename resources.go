@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podResources 工作负载单个 Pod 内所有容器的 request 和 limit 之和，未设置时为 0
+type podResources struct {
+	// CPURequest、CPULimit 单位为核
+	CPURequest float64
+	CPULimit   float64
+	// MemoryRequest、MemoryLimit 单位为字节
+	MemoryRequest float64
+	MemoryLimit   float64
+	// GPU 名称中包含 gpu 的扩展资源（如 nvidia.com/gpu、tke.cloud.tencent.com/qgpu-core）的数量，用于 -gpu
+	GPU float64
+}
+
+func newPodResources(spec corev1.PodSpec) podResources {
+	var r podResources
+	for _, c := range newContainers(spec) {
+		r.CPURequest += c.Resources.CPURequest
+		r.CPULimit += c.Resources.CPULimit
+		r.MemoryRequest += c.Resources.MemoryRequest
+		r.MemoryLimit += c.Resources.MemoryLimit
+		r.GPU += c.Resources.GPU
+	}
+	return r
+}
+
+// container Pod 模板中的一个容器，用于 -containers
+type container struct {
+	Name      string
+	Resources podResources
+}
+
+func newContainers(spec corev1.PodSpec) []container {
+	var containers []container
+	for _, c := range spec.Containers {
+		containers = append(containers, container{
+			Name: c.Name,
+			Resources: podResources{
+				CPURequest:    c.Resources.Requests.Cpu().AsApproximateFloat64(),
+				CPULimit:      c.Resources.Limits.Cpu().AsApproximateFloat64(),
+				MemoryRequest: c.Resources.Requests.Memory().AsApproximateFloat64(),
+				MemoryLimit:   c.Resources.Limits.Memory().AsApproximateFloat64(),
+				GPU:           gpuResources(c.Resources),
+			},
+		})
+	}
+	return containers
+}
+
+// gpuResources 返回容器 request 或 limit 中 GPU 扩展资源数量的较大值
+func gpuResources(r corev1.ResourceRequirements) float64 {
+	var total float64
+	for _, list := range []corev1.ResourceList{r.Requests, r.Limits} {
+		var sum float64
+		for name, q := range list {
+			if strings.Contains(strings.ToLower(string(name)), "gpu") {
+				sum += q.AsApproximateFloat64()
+			}
+		}
+		total = math.Max(total, sum)
+	}
+	return total
+}
+
+// cpuRequestRatioMetric、memRequestRatioMetric 使用量占 request 百分比的指标，用于换算绝对用量
+const (
+	cpuRequestRatioMetric = "K8sWorkloadRateCpuCoreUsedRequestMax"
+	memRequestRatioMetric = "K8sWorkloadRateMemWorkingSetBytesRequestMax"
+)
+
+// resourceColumn -resources 增加的列，Bytes 为 true 时按 Mi/Gi 格式化
+type resourceColumn struct {
+	metricColumn
+	Bytes bool
+	// Metric 换算绝对用量所依赖的百分比指标，为空表示直接取自 Pod spec
+	Metric string
+}
+
+// resourceColumns 返回 -resources 增加的列，绝对用量列只在依赖的百分比指标被采集时输出
+func resourceColumns() []resourceColumn {
+	columns := []resourceColumn{
+		{metricColumn: metricColumn{Name: "CPU Request (cores)", Header: "CPU Request (cores)"}},
+		{metricColumn: metricColumn{Name: "CPU Limit (cores)", Header: "CPU Limit (cores)"}},
+		{metricColumn: metricColumn{Name: "CPU Usage Max per Pod (cores)", Header: statHeader("CPU Usage Max per Pod (cores)", metricStats(cpuRequestRatioMetric)[0])}, Metric: cpuRequestRatioMetric},
+		{metricColumn: metricColumn{Name: "Memory Request", Header: "Memory Request"}, Bytes: true},
+		{metricColumn: metricColumn{Name: "Memory Limit", Header: "Memory Limit"}, Bytes: true},
+		{metricColumn: metricColumn{Name: "Memory Usage Max per Pod", Header: statHeader("Memory Usage Max per Pod", metricStats(memRequestRatioMetric)[0])}, Bytes: true, Metric: memRequestRatioMetric},
+	}
+
+	var active []resourceColumn
+	for _, c := range columns {
+		if c.Metric == "" || isMetricColumn(c.Metric) {
+			active = append(active, c)
+		}
+	}
+	return active
+}
+
+// absoluteMetricColumns -absolute 追加采集的绝对用量指标
+var absoluteMetricColumns = []metricColumn{
+	{Name: "K8sWorkloadCpuCoreUsed", Header: "CPU Used Max (cores)"},
+	{Name: "K8sWorkloadMemWorkingSetBytes", Header: "Memory Working Set Max"},
+}
+
+// limitMetricColumns -limits 追加采集的使用量占 limit 百分比的指标
+var limitMetricColumns = []metricColumn{
+	{Name: "K8sWorkloadRateCpuCoreUsedLimitMax", Header: "CPU Usage Max (% of limit)"},
+	{Name: "K8sWorkloadRateMemWorkingSetBytesLimitMax", Header: "Memory Usage Max (% of limit)"},
+}
+
+// gpuMetricColumns -gpu 追加采集的 GPU 指标
+var gpuMetricColumns = []metricColumn{
+	{Name: "K8sWorkloadRateGpuUsed", Header: "GPU Usage Max (percent)"},
+	{Name: "K8sWorkloadRateGpuMemoryUsed", Header: "GPU Memory Usage Max (percent)"},
+}
+
+// isGPUMetric 判断指标是否为 GPU 指标，包括 metrics 中自行配置的 GPU 指标
+func isGPUMetric(name string) bool {
+	return strings.Contains(name, "Gpu")
+}
+
+// clearGPUValues 去掉 Pod 模板中没有 GPU 资源的工作负载的 GPU 指标，输出 -empty-value 而不是云监控返回的 0
+func clearGPUValues(r *workloadResult) {
+	if r.Resources.GPU > 0 {
+		return
+	}
+	for _, m := range config.Metrics {
+		if !isGPUMetric(m.Name) {
+			continue
+		}
+		for _, stat := range metricStats(m.Name) {
+			delete(r.Values, statColumnName(m.Name, stat))
+		}
+		delete(r.Points, m.Name)
+	}
+}
+
+// bytesMetrics 单位为字节的云监控指标，统计列按 -units 格式化
+var bytesMetrics = map[string]bool{
+	"K8sWorkloadMemWorkingSetBytes":   true,
+	"K8sWorkloadMemUsageBytes":        true,
+	"K8sWorkloadNetworkReceiveBytes":  true,
+	"K8sWorkloadNetworkTransmitBytes": true,
+}
+
+// withExtraMetrics 在 metrics 末尾追加 extra 中尚未配置的指标
+func withExtraMetrics(metrics, extra []metricColumn) []metricColumn {
+	result := append([]metricColumn{}, metrics...)
+	configured := map[string]bool{}
+	for _, m := range metrics {
+		configured[m.Name] = true
+	}
+	for _, m := range extra {
+		if !configured[m.Name] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// isBytesColumn 判断输出列是否为字节数
+func isBytesColumn(name string) bool {
+	if metric, _, ok := statColumnMetric(name); ok {
+		return bytesMetrics[metric]
+	}
+	if isVolumeBytesColumn(name) {
+		return true
+	}
+	if !withResources {
+		return false
+	}
+	columns := resourceColumns()
+	if withRecommend {
+		columns = append(columns, recommendColumns()...)
+	}
+	for _, c := range columns {
+		if c.Name == name {
+			return c.Bytes
+		}
+	}
+	return false
+}
+
+// applyResources 填充 request、limit 以及按百分比换算出的单个 Pod 的绝对用量。
+// 未设置的 request、limit 输出 -empty-value，未设置 request 时也不输出用量
+func applyResources(r *workloadResult) {
+	res := r.Resources
+	values := map[string]float64{}
+	for name, v := range map[string]float64{
+		"CPU Request (cores)": res.CPURequest,
+		"CPU Limit (cores)":   res.CPULimit,
+		"Memory Request":      res.MemoryRequest,
+		"Memory Limit":        res.MemoryLimit,
+	} {
+		if v > 0 {
+			values[name] = v
+		}
+	}
+	if v, ok := r.Values[cpuRequestRatioMetric]; ok && res.CPURequest > 0 {
+		values["CPU Usage Max per Pod (cores)"] = v / 100 * res.CPURequest
+	}
+	if v, ok := r.Values[memRequestRatioMetric]; ok && res.MemoryRequest > 0 {
+		values["Memory Usage Max per Pod"] = v / 100 * res.MemoryRequest
+	}
+
+	for _, c := range resourceColumns() {
+		if v, ok := values[c.Name]; ok {
+			r.Values[c.Name] = v
+		}
+	}
+}
+
+// unitModes -units 支持的内存格式
+var unitModes = []string{"human", "mib", "bytes"}
+
+// formatBytes 按 -units 格式化字节数：human 为 Ki、Mi 或 Gi，mib 为 MiB 数，bytes 为原始字节数
+func formatBytes(v float64) string {
+	switch units {
+	case "mib":
+		return fmt.Sprintf("%.2f", v/(1<<20))
+	case "bytes":
+		return fmt.Sprintf("%.0f", v)
+	}
+	switch {
+	case v >= 1<<30:
+		return fmt.Sprintf("%.2fGi", v/(1<<30))
+	case v >= 1<<20:
+		return fmt.Sprintf("%.2fMi", v/(1<<20))
+	default:
+		return fmt.Sprintf("%.2fKi", v/(1<<10))
+	}
+}
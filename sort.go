@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// sortMetrics -sort-by 可选的排序方式及其对应的指标，name 按工作负载名称排序
+var sortMetrics = map[string]string{
+	"cpu":    cpuRequestRatioMetric,
+	"memory": memRequestRatioMetric,
+	"name":   "",
+}
+
+func validateSort(by, order string, top int) error {
+	metric, ok := sortMetrics[by]
+	if by != "" && !ok {
+		return fmt.Errorf("unsupported -sort-by %q, must be one of cpu, memory, name", by)
+	}
+	if metric != "" && !isMetricColumn(metric) {
+		return fmt.Errorf("-sort-by %s requires metric %s", by, metric)
+	}
+	if order != "asc" && order != "desc" {
+		return fmt.Errorf("unsupported -sort-order %q, must be asc or desc", order)
+	}
+	if top < 0 {
+		return fmt.Errorf("invalid -top %d", top)
+	}
+	return nil
+}
+
+// sortResults 按 -sort-by 排列工作负载，没有数据的工作负载总是排在最后，值相同时按名称排序。
+// 容器行和 Pod 行跟随所属的工作负载行，top 大于 0 时只保留前 top 个工作负载
+func sortResults(results []workloadResult, by, order string, top int) []workloadResult {
+	var groups [][]workloadResult
+	for _, r := range results {
+		if r.isWorkloadRow() || len(groups) == 0 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], r)
+	}
+
+	metric := sortMetrics[by]
+	if by != "" {
+		sort.SliceStable(groups, func(i, j int) bool {
+			a, b := groups[i][0], groups[j][0]
+			if metric != "" {
+				va, okA := a.Values[metric]
+				vb, okB := b.Values[metric]
+				if okA != okB {
+					return okA
+				}
+				if va != vb {
+					if order == "desc" {
+						return va > vb
+					}
+					return va < vb
+				}
+			}
+			if resultKey(a) == resultKey(b) {
+				return false
+			}
+			keyA, keyB := a.Name+"/"+resultKey(a), b.Name+"/"+resultKey(b)
+			if metric == "" && order == "desc" {
+				return keyA > keyB
+			}
+			return keyA < keyB
+		})
+	}
+	if top > 0 && len(groups) > top {
+		groups = groups[:top]
+	}
+
+	var sorted []workloadResult
+	for _, g := range groups {
+		sorted = append(sorted, g...)
+	}
+	return sorted
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// splitBy -split-by 中指定的维度
+var splitBy map[string]bool
+
+// splitDimensions -split-by 支持的维度，目录层级始终按这个顺序
+var splitDimensions = []string{"cluster", "namespace"}
+
+// parseSplitBy 解析逗号分隔的 -split-by，为空时不拆分
+func parseSplitBy(s string) (map[string]bool, error) {
+	dims := map[string]bool{}
+	if s == "" {
+		return dims, nil
+	}
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		if !containsKind(splitDimensions, d) {
+			return nil, fmt.Errorf("unsupported dimension %q, expected one of %v", d, splitDimensions)
+		}
+		dims[d] = true
+	}
+	return dims, nil
+}
+
+// splitPath 返回结果所在的拆分文件，位于完整报告所在的目录下，例如 <cluster>/<namespace>.csv。
+// 单集群时集群名为配置中的 clusterID
+func splitPath(filename string, r workloadResult) string {
+	var parts []string
+	for _, d := range splitDimensions {
+		if !splitBy[d] {
+			continue
+		}
+		switch d {
+		case "cluster":
+			cluster := r.Cluster
+			if cluster == "" {
+				cluster = config.ClusterID
+			}
+			parts = append(parts, cluster)
+		case "namespace":
+			parts = append(parts, r.Namespace)
+		}
+	}
+	return filepath.Join(filepath.Dir(filename), filepath.Join(parts...)+"."+reportWriters[outputFormat].Extension())
+}
+
+// writeSplitReports 按 -split-by 把完整报告的行拆分写入目录树，每个文件与完整报告的列、格式和采集时间相同，
+// 汇总行和覆盖率只统计该文件中的工作负载。容器行和 Pod 行与所属的工作负载在同一个文件中
+func writeSplitReports(filename string, rep *report) error {
+	var paths []string
+	groups := map[string][]workloadResult{}
+	for _, r := range rep.Results {
+		path := splitPath(filename, r)
+		if _, ok := groups[path]; !ok {
+			paths = append(paths, path)
+		}
+		groups[path] = append(groups[path], r)
+	}
+
+	for _, path := range paths {
+		split := *rep
+		split.Results = groups[path]
+		split.Covered, _ = collectionCoverage(workloadRows(split.Results))
+		split.Scanned = len(workloadRows(split.Results))
+		if err := writeReport(path, &split); err != nil {
+			return err
+		}
+	}
+	return nil
+}
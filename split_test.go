@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSplitReports(t *testing.T) {
+	savedSplit, savedFormat := splitBy, outputFormat
+	defer func() { splitBy, outputFormat = savedSplit, savedFormat }()
+	splitBy, outputFormat = map[string]bool{"cluster": true, "namespace": true}, "csv"
+
+	dir := t.TempDir()
+	start := time.Date(2024, 7, 18, 0, 0, 0, 0, time.UTC)
+	rep := newReport(start, start.Add(time.Hour), []workloadResult{
+		{Cluster: "prod", Namespace: "default", Kind: "Deployment", Name: "api"},
+		{Cluster: "prod", Namespace: "payments", Kind: "Deployment", Name: "billing"},
+		{Cluster: "staging", Namespace: "default", Kind: "Deployment", Name: "api"},
+		{Cluster: "prod", Namespace: "default", Kind: "Deployment", Name: "web"},
+	})
+	if err := writeSplitReports(filepath.Join(dir, "report.csv"), rep); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string][]string{
+		"prod/default.csv":    {"api", "web"},
+		"prod/payments.csv":   {"billing"},
+		"staging/default.csv": {"api"},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 表头加上每个工作负载一行
+		if lines := strings.Count(string(data), "\n"); lines != len(want)+1 {
+			t.Errorf("%s has %d lines, want %d:\n%s", path, lines, len(want)+1, data)
+		}
+		for _, name := range want {
+			if !strings.Contains(string(data), ","+name+",") {
+				t.Errorf("%s is missing %s:\n%s", path, name, data)
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// varianceColumn 根据某个指标的数据点计算变异系数的输出列
+type varianceColumn struct {
+	Name   string
+	Metric string
+}
+
+var varianceColumns = []varianceColumn{
+	{Name: "CPU Variance", Metric: "K8sWorkloadRateCpuCoreUsedRequestMax"},
+	{Name: "Mem Variance", Metric: "K8sWorkloadRateMemWorkingSetBytesRequestMax"},
+}
+
+// activeVarianceColumns 返回 -flapping 时所依赖的指标在本次采集范围内的变异系数列
+func activeVarianceColumns() []varianceColumn {
+	if !withFlapping {
+		return nil
+	}
+	var columns []varianceColumn
+	for _, vc := range varianceColumns {
+		if isMetricColumn(vc.Metric) {
+			columns = append(columns, vc)
+		}
+	}
+	return columns
+}
+
+// statLabels 支持的统计方式及其在列名中的写法
+var statLabels = map[string]string{
+	"max": "Max",
+	"min": "Min",
+	"avg": "Avg",
+	"p50": "P50",
+	"p95": "P95",
+	"p99": "P99",
+}
+
+// stats 由 -stat 指定的统计方式，第一个为主统计值，供表达式、基线和 -summary-only 使用
+var stats = []string{"max"}
+
+// parseStats 解析逗号分隔的统计方式
+func parseStats(s string) ([]string, error) {
+	var result []string
+	seen := map[string]bool{}
+	for _, stat := range strings.Split(s, ",") {
+		stat = strings.TrimSpace(stat)
+		if _, ok := statLabels[stat]; !ok {
+			return nil, fmt.Errorf("unsupported statistic %q", stat)
+		}
+		if !seen[stat] {
+			result = append(result, stat)
+			seen[stat] = true
+		}
+	}
+	return result, nil
+}
+
+// metricStats 返回指标的统计方式：metrics 中配置了 stat 时使用该配置，否则使用 -stat
+func metricStats(metric string) []string {
+	for _, m := range config.Metrics {
+		if m.Name == metric && m.Stat != "" {
+			if s, err := parseStats(m.Stat); err == nil {
+				return s
+			}
+		}
+	}
+	return stats
+}
+
+// statColumnName 统计值在 workloadResult.Values 中的 key，指标的主统计值直接使用指标名
+func statColumnName(metric, stat string) string {
+	if stat == metricStats(metric)[0] {
+		return metric
+	}
+	return metric + ":" + stat
+}
+
+// statColumnMetric 把统计列的 key 还原为指标名和统计方式，不是统计列时返回 false
+func statColumnMetric(name string) (metric, stat string, ok bool) {
+	for _, m := range config.Metrics {
+		for _, stat := range metricStats(m.Name) {
+			if statColumnName(m.Name, stat) == name {
+				return m.Name, stat, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// statHeader 把列名中的 Max 替换为对应的统计方式，如 CPU Usage P95 (percent)，
+// 列名中没有 Max 时在末尾追加统计方式
+func statHeader(header, stat string) string {
+	if strings.Contains(header, "Max") {
+		return strings.Replace(header, "Max", statLabels[stat], 1)
+	}
+	if stat == "max" {
+		return header
+	}
+	return header + " (" + statLabels[stat] + ")"
+}
+
+// statColumns 按指标、统计方式的顺序展开输出的指标列
+func statColumns() []metricColumn {
+	var columns []metricColumn
+	for _, m := range config.Metrics {
+		for _, stat := range metricStats(m.Name) {
+			columns = append(columns, metricColumn{Name: statColumnName(m.Name, stat), Header: statHeader(m.Header, stat)})
+		}
+	}
+	return columns
+}
+
+// statValues 计算每个指标数据点的各项统计值
+func statValues(points map[string][]float64) map[string]float64 {
+	result := map[string]float64{}
+	for name, values := range points {
+		sorted := append([]float64{}, values...)
+		sort.Float64s(sorted)
+		for _, stat := range metricStats(name) {
+			result[statColumnName(name, stat)] = statistic(stat, sorted)
+		}
+	}
+	return result
+}
+
+// statistic 根据排好序的数据点计算统计值，百分位使用 nearest-rank 方法
+func statistic(stat string, sorted []float64) float64 {
+	switch stat {
+	case "min":
+		return sorted[0]
+	case "avg":
+		var sum float64
+		for _, v := range sorted {
+			sum += v
+		}
+		return sum / float64(len(sorted))
+	case "p50":
+		return percentile(sorted, 50)
+	case "p95":
+		return percentile(sorted, 95)
+	case "p99":
+		return percentile(sorted, 99)
+	default:
+		return sorted[len(sorted)-1]
+	}
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// coefficientOfVariation 返回总体标准差与均值的比值，少于两个数据点或均值为 0 时返回 false
+func coefficientOfVariation(values []float64) (float64, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0, false
+	}
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sq/float64(len(values))) / mean, true
+}
+
+// explainStats 打印每个指标的原始数据点以及各统计值的计算过程
+func explainStats(w io.Writer, r workloadResult) {
+	fmt.Fprintf(w, "workload %s/%s\n", r.Namespace, r.Name)
+	for _, m := range requestedMetrics() {
+		values, ok := r.Points[m]
+		fmt.Fprintf(w, "\n%s\n", m)
+		if !ok {
+			fmt.Fprintf(w, "  no data points, reported as %q\n", emptyValue)
+			continue
+		}
+
+		sorted := append([]float64{}, values...)
+		sort.Float64s(sorted)
+		fmt.Fprintf(w, "  points (%d): %v\n", len(values), values)
+		fmt.Fprintf(w, "  sorted:     %v\n", sorted)
+		for _, stat := range metricStats(m) {
+			switch stat {
+			case "avg":
+				fmt.Fprintf(w, "  avg = sum / %d = %f\n", len(sorted), statistic(stat, sorted))
+			case "max":
+				fmt.Fprintf(w, "  max = sorted[%d] = %f\n", len(sorted)-1, sorted[len(sorted)-1])
+			case "min":
+				fmt.Fprintf(w, "  min = sorted[0] = %f\n", sorted[0])
+			default:
+				fmt.Fprintf(w, "  %s = sorted[ceil(%s/100 * %d) - 1] = %f\n", stat, stat[1:], len(sorted), statistic(stat, sorted))
+			}
+		}
+	}
+
+	for _, vc := range activeVarianceColumns() {
+		values := r.Points[vc.Metric]
+		fmt.Fprintf(w, "\n%s (%s)\n", vc.Name, vc.Metric)
+		if len(values) < 2 {
+			fmt.Fprintf(w, "  fewer than 2 points, reported as %q\n", emptyValue)
+			continue
+		}
+
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		mean := sum / float64(len(values))
+		fmt.Fprintf(w, "  mean = %f / %d = %f\n", sum, len(values), mean)
+		if mean == 0 {
+			fmt.Fprintf(w, "  mean is 0, reported as %q\n", emptyValue)
+			continue
+		}
+
+		var sq float64
+		for _, v := range values {
+			sq += (v - mean) * (v - mean)
+		}
+		stddev := math.Sqrt(sq / float64(len(values)))
+		fmt.Fprintf(w, "  stddev = sqrt(%f / %d) = %f\n", sq, len(values), stddev)
+		fmt.Fprintf(w, "  cv = stddev / mean = %f\n", stddev/mean)
+	}
+}
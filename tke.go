@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	"k8s.io/klog/v2"
+)
+
+// tkeEndpoint TKE API 地址
+const tkeEndpoint = "tke.tencentcloudapi.com"
+
+// describeClustersPageSize DescribeClusters 每页返回的集群数，接口允许的最大值为 100
+const describeClustersPageSize = 100
+
+// ClusterDiscovery 通过 TKE DescribeClusters 接口发现需要采集的集群，使用顶层配置的凭证
+type ClusterDiscovery struct {
+	// Regions 需要发现集群的地域，为空时使用顶层的 region，发现的集群使用所在的地域采集监控数据
+	Regions []string `yaml:"regions"`
+	// Names 集群名称的 glob，例如 prod-*，为空时不按名称过滤
+	Names []string `yaml:"names"`
+	// Tags 集群需要带有的全部标签
+	Tags map[string]string `yaml:"tags"`
+	// Context 集群在 kubeconfig 中的 context，其中的 {clusterID} 替换为集群 ID，为空时使用 current-context
+	Context string `yaml:"context"`
+}
+
+// matchName 判断集群名称是否匹配 Names 中的 glob
+func (d ClusterDiscovery) matchName(name string) bool {
+	if len(d.Names) == 0 {
+		return true
+	}
+	for _, pattern := range d.Names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type tkeFilter struct {
+	Name   string   `json:"Name"`
+	Values []string `json:"Values"`
+}
+
+// describeClustersRequest TKE 2018-05-25 版本的 DescribeClusters 请求，只包含用到的参数
+type describeClustersRequest struct {
+	*tchttp.BaseRequest
+	Filters []tkeFilter `json:"Filters,omitempty"`
+	Offset  int64       `json:"Offset"`
+	Limit   int64       `json:"Limit"`
+}
+
+type tkeCluster struct {
+	ClusterId     string `json:"ClusterId"`
+	ClusterName   string `json:"ClusterName"`
+	ClusterStatus string `json:"ClusterStatus"`
+}
+
+type describeClustersResponse struct {
+	*tchttp.BaseResponse
+	Response struct {
+		TotalCount int64        `json:"TotalCount"`
+		Clusters   []tkeCluster `json:"Clusters"`
+		RequestId  string       `json:"RequestId"`
+	} `json:"Response"`
+}
+
+// regions 返回需要发现集群的地域
+func (d ClusterDiscovery) regions(c Config) []string {
+	if len(d.Regions) > 0 {
+		return d.Regions
+	}
+	return []string{c.Region}
+}
+
+// discoverClusters 列出各地域下匹配 discoverClusters 条件的运行中集群，返回的集群按地域和 ID 排序
+func discoverClusters(ctx context.Context, c Config) ([]ClusterConfig, error) {
+	d := *c.DiscoverClusters
+	var clusters []ClusterConfig
+	for _, region := range d.regions(c) {
+		found, err := discoverRegionClusters(ctx, c, region)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, found...)
+	}
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no running clusters in %s match discoverClusters", strings.Join(d.regions(c), ", "))
+	}
+	klog.Infof("discovered %d clusters", len(clusters))
+	return clusters, nil
+}
+
+// discoverRegionClusters 列出一个地域下匹配的运行中集群，按 ID 排序
+func discoverRegionClusters(ctx context.Context, c Config, region string) ([]ClusterConfig, error) {
+	d := *c.DiscoverClusters
+	top := c.clusters()[0]
+
+	credential := common.NewCredential(top.SecretID, top.SecretKey)
+	if top.SessionToken != "" {
+		credential = common.NewTokenCredential(top.SecretID, top.SecretKey, top.SessionToken)
+	}
+	cpf := profile.NewClientProfile()
+	cpf.HttpProfile.Endpoint = tkeEndpoint
+	transport, err := newMonitorTransport()
+	if err != nil {
+		return nil, err
+	}
+	client := common.NewCommonClient(credential, region, cpf)
+	client.WithHttpTransport(transport)
+
+	var filters []tkeFilter
+	for _, k := range sortedKeys(d.Tags) {
+		filters = append(filters, tkeFilter{Name: "tag:" + k, Values: []string{d.Tags[k]}})
+	}
+
+	var clusters []ClusterConfig
+	for offset := int64(0); ; offset += describeClustersPageSize {
+		request := &describeClustersRequest{BaseRequest: &tchttp.BaseRequest{}, Filters: filters, Offset: offset, Limit: describeClustersPageSize}
+		request.Init().WithApiInfo("tke", "2018-05-25", "DescribeClusters")
+		request.SetContext(ctx)
+		response := &describeClustersResponse{BaseResponse: &tchttp.BaseResponse{}}
+		if err := client.Send(request, response); err != nil {
+			return nil, fmt.Errorf("describe clusters in %s: %v", region, err)
+		}
+
+		for _, cl := range response.Response.Clusters {
+			if !d.matchName(cl.ClusterName) {
+				continue
+			}
+			if cl.ClusterStatus != "Running" {
+				klog.Infof("skip cluster %s (%s): status is %s", cl.ClusterId, cl.ClusterName, cl.ClusterStatus)
+				continue
+			}
+			clusters = append(clusters, ClusterConfig{
+				ClusterID: cl.ClusterId,
+				Region:    region,
+				Context:   strings.ReplaceAll(d.Context, "{clusterID}", cl.ClusterId),
+			})
+		}
+		if len(response.Response.Clusters) < describeClustersPageSize || offset+describeClustersPageSize >= response.Response.TotalCount {
+			break
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].ClusterID < clusters[j].ClusterID })
+	klog.V(2).Infof("discovered %d clusters in %s", len(clusters), region)
+	return clusters, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// tracer 未配置 -otlp-endpoint 时使用全局的 noop provider，不产生任何开销
+var tracer = otel.Tracer("github.com/coderwangke/tke-workload-metrics")
+
+// flushTracing 刷新尚未导出的 span，退出前必须调用
+var flushTracing = func() {}
+
+// exit 刷新 span 后以 code 退出
+func exit(code int) {
+	flushTracing()
+	os.Exit(code)
+}
+
+// setupTracing 配置通过 OTLP/HTTP 导出 span
+func setupTracing(ctx context.Context, endpoint string, insecure bool) error {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("tke-workload-metrics"))),
+	)
+	otel.SetTracerProvider(provider)
+	tracer = provider.Tracer("github.com/coderwangke/tke-workload-metrics")
+
+	flushTracing = func() {
+		provider.Shutdown(context.Background())
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// -volumes 增加的列，容量和已用量为字节数
+const (
+	volumeCapacityColumn = "Volume Capacity"
+	volumeUsedColumn     = "Volume Used"
+	volumeUsageColumn    = "Volume Usage (percent)"
+)
+
+func volumeColumns() []metricColumn {
+	return []metricColumn{
+		{Name: volumeCapacityColumn, Header: volumeCapacityColumn},
+		{Name: volumeUsedColumn, Header: volumeUsedColumn},
+		{Name: volumeUsageColumn, Header: volumeUsageColumn},
+	}
+}
+
+// isVolumeBytesColumn 判断 -volumes 增加的列是否为字节数
+func isVolumeBytesColumn(name string) bool {
+	return withVolumes && (name == volumeCapacityColumn || name == volumeUsedColumn)
+}
+
+// claimNames 返回 Pod 模板中直接引用的 PVC
+func claimNames(spec corev1.PodSpec) []string {
+	var claims []string
+	for _, v := range spec.Volumes {
+		if v.PersistentVolumeClaim != nil {
+			claims = append(claims, v.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return claims
+}
+
+// statefulSetClaims 返回 StatefulSet 的 Pod 使用的 PVC，包括 volumeClaimTemplates 为每个副本创建的 <template>-<statefulset>-<ordinal>
+func statefulSetClaims(sts appsv1.StatefulSet) []string {
+	claims := claimNames(sts.Spec.Template.Spec)
+	for _, t := range sts.Spec.VolumeClaimTemplates {
+		for i := int32(0); i < replicas(sts.Spec.Replicas); i++ {
+			claims = append(claims, fmt.Sprintf("%s-%s-%d", t.Name, sts.Name, i))
+		}
+	}
+	return claims
+}
+
+// volumeStats 一个 PVC 的容量和已用量，单位为字节
+type volumeStats struct {
+	Capacity float64
+	Used     float64
+}
+
+// kubeletSummary kubelet /stats/summary 响应中用到的字段
+type kubeletSummary struct {
+	Pods []struct {
+		Volume []struct {
+			CapacityBytes *uint64 `json:"capacityBytes"`
+			UsedBytes     *uint64 `json:"usedBytes"`
+			PVCRef        *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// listVolumeUsage 通过 API Server 代理读取每个节点 kubelet 的 /stats/summary，返回 namespace/name 到 PVC 当前用量的映射。
+// 单个节点读取失败时打印警告并跳过
+func listVolumeUsage(ctx context.Context, clientset kubernetes.Interface) (map[string]volumeStats, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list nodes: %v", err)
+	}
+
+	usage := map[string]volumeStats{}
+	for _, node := range nodes.Items {
+		body, err := clientset.CoreV1().RESTClient().Get().Resource("nodes").Name(node.Name).SubResource("proxy", "stats", "summary").DoRaw(ctx)
+		if err != nil {
+			klog.Warningf("read volume stats of node %s failed: %v", node.Name, err)
+			continue
+		}
+		var summary kubeletSummary
+		if err := json.Unmarshal(body, &summary); err != nil {
+			klog.Warningf("parse volume stats of node %s failed: %v", node.Name, err)
+			continue
+		}
+		for _, pod := range summary.Pods {
+			for _, v := range pod.Volume {
+				if v.PVCRef == nil || v.CapacityBytes == nil || v.UsedBytes == nil {
+					continue
+				}
+				usage[v.PVCRef.Namespace+"/"+v.PVCRef.Name] = volumeStats{Capacity: float64(*v.CapacityBytes), Used: float64(*v.UsedBytes)}
+			}
+		}
+	}
+	return usage, nil
+}
+
+// applyVolumes 汇总工作负载所有 PVC 的容量和已用量，没有读取到任何 PVC 的用量时输出 -empty-value
+func applyVolumes(r *workloadResult, usage map[string]volumeStats) {
+	var total volumeStats
+	found := false
+	for _, claim := range r.Claims {
+		if s, ok := usage[r.Namespace+"/"+claim]; ok {
+			total.Capacity += s.Capacity
+			total.Used += s.Used
+			found = true
+		}
+	}
+	if !found {
+		return
+	}
+	r.Values[volumeCapacityColumn] = total.Capacity
+	r.Values[volumeUsedColumn] = total.Used
+	if total.Capacity > 0 {
+		r.Values[volumeUsageColumn] = total.Used / total.Capacity * 100
+	}
+}
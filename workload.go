@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coderwangke/tke-workload-metrics/collector"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// workload 需要采集监控数据的工作负载
+type workload struct {
+	Kind string
+	metav1.ObjectMeta
+	// Resources 单个 Pod 的 request 和 limit
+	Resources podResources
+	// Containers Pod 模板中的容器
+	Containers []container
+	// Replicas 期望的副本数，DaemonSet 为需要调度的节点数
+	Replicas int32
+	// Claims Pod 使用的 PVC，用于 -volumes
+	Claims []string
+	// Pods 当前属于工作负载的 Pod，用于 -granularity pod
+	Pods []string
+}
+
+// target 返回 collector 采集时使用的工作负载标识
+func (w workload) target() collector.Workload {
+	return collector.Workload{Namespace: w.Namespace, Kind: w.Kind, Name: w.Name}
+}
+
+// supportedWorkloadKinds 支持通过 Apps API 和 Batch API 列出的工作负载类型
+var supportedWorkloadKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob"}
+
+func isSupportedWorkloadKind(kind string) bool {
+	for _, k := range supportedWorkloadKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// listWorkloads 列出命名空间下匹配 selector 的指定类型的工作负载，每次请求最多返回 -page-size 个。
+// 集群中没有对应资源时返回空列表而不是报错
+func listWorkloads(ctx context.Context, clients clusterClients, namespace, selector string, kinds []string) ([]workload, error) {
+	var workloads []workload
+	for _, kind := range kinds {
+		opts := metav1.ListOptions{LabelSelector: selector, Limit: pageSize}
+		var items []workload
+		var err error
+		if custom, ok := customWorkloadKind(config, kind); ok {
+			items, err = listCustomWorkloads(ctx, clients.Dynamic, namespace, custom, opts)
+		} else {
+			items, err = listWorkloadsOfKind(ctx, clients, namespace, kind, opts)
+		}
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list %s in %s: %v", kind, namespace, err)
+		}
+		workloads = append(workloads, items...)
+	}
+	if dedupOwned {
+		workloads = withoutOwnedWorkloads(workloads, kinds)
+	}
+	return workloads, nil
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutOwnedWorkloads 去掉 controller 为另一个已列出的工作负载的子工作负载，例如 workloadKinds 中同时配置了
+// Deployment 和作为 customWorkloadKinds 的 ReplicaSet 时 Deployment 管理的 ReplicaSet，以及 kinds 包含 CronJob 时由 CronJob 创建的 Job。
+// 这些子工作负载的 Pod 已经归属到上层工作负载统计，避免重复输出和汇总时重复计算
+func withoutOwnedWorkloads(workloads []workload, kinds []string) []workload {
+	listed := map[string]bool{}
+	for _, w := range workloads {
+		listed[w.Kind+"/"+w.Name] = true
+	}
+	var kept []workload
+	for _, w := range workloads {
+		if owner := metav1.GetControllerOfNoCopy(&w.ObjectMeta); owner != nil {
+			if listed[owner.Kind+"/"+owner.Name] || (w.Kind == "Job" && owner.Kind == "CronJob" && containsKind(kinds, "CronJob")) {
+				continue
+			}
+		}
+		kept = append(kept, w)
+	}
+	return kept
+}
+
+// replicas 返回 spec.replicas 或 Job 的 spec.parallelism，未设置时为默认的 1
+func replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
+
+// listWorkloadsOfKind 按 opts.Limit 分页列出一种工作负载，直到 Continue 为空
+func listWorkloadsOfKind(ctx context.Context, clientset kubernetes.Interface, namespace, kind string, opts metav1.ListOptions) ([]workload, error) {
+	var workloads []workload
+	for {
+		var next string
+		switch kind {
+		case "Deployment":
+			list, err := clientset.AppsV1().Deployments(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range list.Items {
+				workloads = append(workloads, workload{Kind: kind, ObjectMeta: item.ObjectMeta, Resources: newPodResources(item.Spec.Template.Spec), Containers: newContainers(item.Spec.Template.Spec), Replicas: replicas(item.Spec.Replicas), Claims: claimNames(item.Spec.Template.Spec)})
+			}
+			next = list.Continue
+		case "StatefulSet":
+			list, err := clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range list.Items {
+				workloads = append(workloads, workload{Kind: kind, ObjectMeta: item.ObjectMeta, Resources: newPodResources(item.Spec.Template.Spec), Containers: newContainers(item.Spec.Template.Spec), Replicas: replicas(item.Spec.Replicas), Claims: statefulSetClaims(item)})
+			}
+			next = list.Continue
+		case "DaemonSet":
+			list, err := clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range list.Items {
+				workloads = append(workloads, workload{Kind: kind, ObjectMeta: item.ObjectMeta, Resources: newPodResources(item.Spec.Template.Spec), Containers: newContainers(item.Spec.Template.Spec), Replicas: item.Status.DesiredNumberScheduled, Claims: claimNames(item.Spec.Template.Spec)})
+			}
+			next = list.Continue
+		case "Job":
+			list, err := clientset.BatchV1().Jobs(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range list.Items {
+				workloads = append(workloads, workload{Kind: kind, ObjectMeta: item.ObjectMeta, Resources: newPodResources(item.Spec.Template.Spec), Containers: newContainers(item.Spec.Template.Spec), Replicas: replicas(item.Spec.Parallelism), Claims: claimNames(item.Spec.Template.Spec)})
+			}
+			next = list.Continue
+		case "CronJob":
+			list, err := clientset.BatchV1().CronJobs(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range list.Items {
+				spec := item.Spec.JobTemplate.Spec
+				workloads = append(workloads, workload{Kind: kind, ObjectMeta: item.ObjectMeta, Resources: newPodResources(spec.Template.Spec), Containers: newContainers(spec.Template.Spec), Replicas: replicas(spec.Parallelism), Claims: claimNames(spec.Template.Spec)})
+			}
+			next = list.Continue
+		default:
+			return nil, fmt.Errorf("unsupported workload kind %s", kind)
+		}
+		if next == "" {
+			return workloads, nil
+		}
+		opts.Continue = next
+	}
+}
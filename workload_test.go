@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestListWorkloadsDedupOwned 同时列出 Deployment 和作为自定义类型的 ReplicaSet 时，Deployment 管理的 ReplicaSet 默认被跳过
+func TestListWorkloadsDedupOwned(t *testing.T) {
+	savedConfig, savedDedup, savedPageSize := config, dedupOwned, pageSize
+	defer func() { config, dedupOwned, pageSize = savedConfig, savedDedup, savedPageSize }()
+	pageSize = 500
+	config.CustomWorkloadKinds = []CustomWorkloadKind{{Kind: "ReplicaSet", Group: "apps", Version: "v1", Resource: "replicasets"}}
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid"}}
+	controller := true
+	owned := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "ReplicaSet",
+		"metadata": map[string]interface{}{
+			"name":      "web-5d4f8",
+			"namespace": "default",
+			"ownerReferences": []interface{}{
+				map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web", "uid": "web-uid", "controller": controller},
+			},
+		},
+	}}
+	standalone := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "ReplicaSet",
+		"metadata":   map[string]interface{}{"name": "legacy", "namespace": "default"},
+	}}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}
+	clients := clusterClients{
+		Interface: fake.NewSimpleClientset(deployment),
+		Dynamic:   dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ReplicaSetList"}, owned, standalone),
+	}
+
+	for _, test := range []struct {
+		dedup bool
+		want  []string
+	}{
+		{dedup: true, want: []string{"Deployment/web", "ReplicaSet/legacy"}},
+		{dedup: false, want: []string{"Deployment/web", "ReplicaSet/legacy", "ReplicaSet/web-5d4f8"}},
+	} {
+		dedupOwned = test.dedup
+		workloads, err := listWorkloads(context.Background(), clients, "default", "", []string{"Deployment", "ReplicaSet"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, w := range workloads {
+			got = append(got, w.Kind+"/"+w.Name)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("-dedup-owned=%v: got %v, want %v", test.dedup, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("-dedup-owned=%v: got %v, want %v", test.dedup, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxSheetModes -xlsx-sheets 支持的分表方式
+var xlsxSheetModes = []string{"namespace", "cluster", "single"}
+
+// maxSheetNameLength Excel 工作表名称的最大长度
+const maxSheetNameLength = 31
+
+type xlsxReportWriter struct{}
+
+func (xlsxReportWriter) Extension() string { return "xlsx" }
+
+// Write 第一个工作表为汇总，之后按 -xlsx-sheets 每个命名空间或集群一个工作表，
+// 使用率列低于 -over-provisioned-below 标黄，不低于 -under-provisioned-above 标红
+func (xlsxReportWriter) Write(w io.Writer, r *report) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), "Summary"); err != nil {
+		return err
+	}
+	if err := writeSheetRows(f, "Summary", r.xlsxSummaryRows(), false); err != nil {
+		return err
+	}
+
+	styles, err := newUtilizationStyles(f)
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{"Summary": true}
+	for _, group := range r.sheetGroups() {
+		sheet := uniqueSheetName(group.name, names)
+		if _, err := f.NewSheet(sheet); err != nil {
+			return err
+		}
+		rows := [][]string{r.header()}
+		for _, result := range group.results {
+			rows = append(rows, r.row(result))
+		}
+		if err := writeSheetRows(f, sheet, rows, true); err != nil {
+			return err
+		}
+		if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+			return fmt.Errorf("freeze header: %v", err)
+		}
+		if err := r.highlightUtilization(f, sheet, len(rows), styles); err != nil {
+			return fmt.Errorf("conditional format: %v", err)
+		}
+	}
+
+	_, err = f.WriteTo(w)
+	return err
+}
+
+// writeSheetRows 从 A1 开始写入行，numeric 为 true 时表头以外的数值单元格写为数字，便于在 Excel 中排序和计算
+func writeSheetRows(f *excelize.File, sheet string, rows [][]string, numeric bool) error {
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			if n, err := parseNumber(v); err == nil && numeric && i > 0 {
+				values[j] = n
+			} else {
+				values[j] = v
+			}
+		}
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xlsxSummaryRows 汇总工作表：时间范围、集群以及 summaryRows 中的各列统计
+func (r *report) xlsxSummaryRows() [][]string {
+	rows := [][]string{
+		{"Start", r.StartTime.Format("2006-01-02 15:04:05 MST")},
+		{"End", r.EndTime.Format("2006-01-02 15:04:05 MST")},
+	}
+	rows = append(rows, []string{"Clusters", strings.Join(reportClusters(), ", ")}, []string{})
+	// summaryRows 的第一行是标题
+	return append(rows, r.summaryRows()[1:]...)
+}
+
+// sheetGroup 一个工作表中的行
+type sheetGroup struct {
+	name    string
+	results []workloadResult
+}
+
+// sheetGroups 按 -xlsx-sheets 把结果分到不同的工作表，工作表按第一次出现的顺序排列
+func (r *report) sheetGroups() []sheetGroup {
+	var groups []sheetGroup
+	index := map[string]int{}
+	for _, result := range r.Results {
+		name := "Workloads"
+		switch xlsxSheets {
+		case "namespace":
+			name = result.Namespace
+			if r.MultiCluster {
+				name = result.Cluster + "-" + result.Namespace
+			}
+		case "cluster":
+			if result.Cluster != "" {
+				name = result.Cluster
+			}
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, sheetGroup{name: name})
+		}
+		groups[i].results = append(groups[i].results, result)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, sheetGroup{name: "Workloads"})
+	}
+	return groups
+}
+
+// uniqueSheetName 替换 Excel 不允许的字符并截断到 31 个字符，与已有工作表重名时追加序号
+func uniqueSheetName(name string, used map[string]bool) string {
+	name = strings.NewReplacer(":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "_", "]", "_").Replace(name)
+	if len(name) > maxSheetNameLength {
+		name = name[:maxSheetNameLength]
+	}
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		suffix := "~" + strconv.Itoa(i)
+		base := name
+		if len(base)+len(suffix) > maxSheetNameLength {
+			base = base[:maxSheetNameLength-len(suffix)]
+		}
+		candidate = base + suffix
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// utilizationStyles 使用率列条件格式的样式
+type utilizationStyles struct {
+	low, high int
+}
+
+func newUtilizationStyles(f *excelize.File) (utilizationStyles, error) {
+	low, err := f.NewConditionalStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFEB9C"}}})
+	if err != nil {
+		return utilizationStyles{}, err
+	}
+	high, err := f.NewConditionalStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}}})
+	if err != nil {
+		return utilizationStyles{}, err
+	}
+	return utilizationStyles{low: low, high: high}, nil
+}
+
+// isUtilizationColumn 统计列对应的指标是占 request 或 limit 百分比的 Rate 类指标
+func isUtilizationColumn(name string) bool {
+	metric, _, ok := statColumnMetric(name)
+	return ok && strings.Contains(metric, "Rate") && (strings.Contains(metric, "Request") || strings.Contains(metric, "Limit"))
+}
+
+// highlightUtilization 为使用率列设置条件格式，rows 包括表头
+func (r *report) highlightUtilization(f *excelize.File, sheet string, rows int, styles utilizationStyles) error {
+	if rows < 2 {
+		return nil
+	}
+	for i, m := range r.Columns {
+		if !isUtilizationColumn(m.Name) {
+			continue
+		}
+		col := r.columnIndex(i)
+		from, err := excelize.CoordinatesToCellName(col, 2)
+		if err != nil {
+			return err
+		}
+		to, err := excelize.CoordinatesToCellName(col, rows)
+		if err != nil {
+			return err
+		}
+		err = f.SetConditionalFormat(sheet, from+":"+to, []excelize.ConditionalFormatOptions{
+			{Type: "cell", Criteria: "<", Format: styles.low, Value: strconv.FormatFloat(overProvisionedBelow, 'f', -1, 64)},
+			{Type: "cell", Criteria: ">=", Format: styles.high, Value: strconv.FormatFloat(underProvisionedAbove, 'f', -1, 64)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnIndex 返回 r.Columns 中第 i 列在表格中的列号，从 1 开始
+func (r *report) columnIndex(i int) int {
+	n := 3
+	if r.Containers {
+		n++
+	}
+	if r.Pods {
+		n++
+	}
+	if r.MultiCluster {
+		n++
+	}
+	return n + i + 1
+}